@@ -1,35 +1,184 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reparttask/config"
 	"reparttask/internal/order"
 	"reparttask/internal/pack"
+	"reparttask/service"
 	"reparttask/service/bestfit"
+	"reparttask/service/cache"
+	"reparttask/service/dp"
+	"reparttask/service/precomputed"
+	"reparttask/storage"
+	"reparttask/storage/file"
 	"reparttask/storage/memory"
+	"strings"
+	"time"
 )
 
 func main() {
+	cfg, err := config.ParseConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	calc, err := newCalculator(cfg.Calculator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	newStorage := newStorageFactory(cfg.DataDir)
+	base, err := newStorage(storage.DefaultSKU)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	router := http.NewServeMux()
-	db := memory.NewMemDB()
-	calc := bestfit.NewCalc()
+	db := storage.NewObserved(base)
 
-	packHandler := pack.NewHandler(db)
+	// the answers are precomputed again in the background whenever the pack set changes.
+	// The table follows the dp rules, so it never replaces another calculator.
+	_, exact := calc.(*dp.Calc)
+	if cfg.PrecomputeMax > 0 && !exact {
+		log.Printf("PRECOMPUTE_MAX is ignored, answers are only precomputed for the dp calculator, not %q", cfg.Calculator)
+	}
+	if cfg.PrecomputeMax > 0 && exact {
+		table := precomputed.New(calc, cfg.PrecomputeMax)
+		rebuild := func() {
+			packs, err := db.GetPacks(context.Background())
+			if err != nil {
+				log.Println("precomputing the answers:", err)
+				return
+			}
+
+			table.Rebuild(storage.Sizes(packs))
+		}
+		db.OnChange(rebuild)
+		// packs restored from the data directory are precomputed right away.
+		rebuild()
+		calc = table
+	}
+
+	// cached results are dropped whenever the pack set changes.
+	if cfg.CacheSize > 0 {
+		cached := cache.New(calc, cfg.CacheSize)
+		db.OnChange(cached.Purge)
+		expvar.Publish("calc_cache", expvar.Func(func() any { return cached.Stats() }))
+		calc = cached
+	}
+	router.Handle("GET /debug/vars", expvar.Handler())
+
+	// the unscoped endpoints use the default product, the precomputed table follows its packs.
+	products := storage.NewProducts(db, newStorage)
+	if err := loadProducts(cfg.DataDir, products); err != nil {
+		log.Fatal(err)
+	}
+
+	packHandler := pack.NewHandler(products)
 	packHandler.RegisterRoutes(router)
 
-	orderHandler := order.NewHandler(db, calc)
-	orderHandler.RegisterRoutes(router)
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
 
-	cfg, err := config.ParseConfig()
+	orders, err := newOrderStore(cfg.DataDir)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	orderHandler := order.NewHandler(products, calc, objectives, order.Options{
+		BatchLimit: cfg.BatchLimit,
+		Timeout:    cfg.CalcTimeout,
+		Orders:     orders,
+	})
+	orderHandler.RegisterRoutes(router)
+
+	// scheduled pack changes are applied in the background once due.
+	ticker := time.NewTicker(cfg.ScheduleEvery)
+	defer ticker.Stop()
+	go scheduler{products: products}.run(context.Background(), ticker.C)
+
 	log.Println("Listening on port:", cfg.Port)
 	err = http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), router)
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newCalculator returns the calculator implementation configured by name.
+func newCalculator(name string) (service.Calculator, error) {
+	switch name {
+	case "dp":
+		return dp.NewCalc(), nil
+	case "bestfit":
+		return bestfit.NewCalc(), nil
+	default:
+		return nil, fmt.Errorf("unknown calculator %q", name)
+	}
+}
+
+// newStorageFactory returns the function building the storage of a product,
+// products are kept in memory unless a data directory is configured.
+func newStorageFactory(dataDir string) func(sku string) (storage.Storage, error) {
+	if dataDir == "" {
+		return func(string) (storage.Storage, error) { return memory.NewMemDB(), nil }
+	}
+
+	return func(sku string) (storage.Storage, error) {
+		// the catalog validates the SKU already, a product must still never be stored outside the data directory.
+		dir := filepath.Join(dataDir, sku)
+		rel, err := filepath.Rel(dataDir, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, storage.ErrInvalidSKU
+		}
+
+		return file.NewFileDB(dir)
+	}
+}
+
+// newOrderStore returns the store of the orders placed, they are kept in memory
+// unless a data directory is configured.
+func newOrderStore(dataDir string) (storage.OrderStore, error) {
+	if dataDir == "" {
+		return memory.NewOrderDB(), nil
+	}
+
+	return file.NewOrderDB(dataDir)
+}
+
+// loadProducts opens every product stored in the data directory, other directories are skipped.
+func loadProducts(dataDir string, products storage.Catalog) error {
+	if dataDir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		// other directories, eg. lost+found on a mounted volume, are left alone.
+		dir := filepath.Join(dataDir, entry.Name())
+		if !storage.ValidSKU(entry.Name()) || !file.Stored(dir) {
+			log.Printf("skipping %q in the data directory, it isn't a product", dir)
+			continue
+		}
+
+		if _, err := products.Product(entry.Name()); err != nil {
+			return fmt.Errorf("loading product %q: %w", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"testing"
+)
+
+func TestStorageFactoryDataDir(t *testing.T) {
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "data")
+	newStorage := newStorageFactory(dataDir)
+
+	db, err := newStorage("SKU-1")
+	assert.NoError(t, err)
+	assert.NotNil(t, db)
+	assert.DirExists(t, filepath.Join(dataDir, "SKU-1"))
+
+	// a product is never stored in the data directory itself or outside of it.
+	for _, sku := range []string{".", "..", "../SKU-1", "SKU-1/../.."} {
+		_, err := newStorage(sku)
+		assert.True(t, errors.Is(err, storage.ErrInvalidSKU), sku)
+	}
+
+	entries, err := os.ReadDir(root)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestLoadProducts(t *testing.T) {
+	dataDir := t.TempDir()
+	newStorage := newStorageFactory(dataDir)
+
+	base, err := newStorage(storage.DefaultSKU)
+	assert.NoError(t, err)
+	_, err = newStorage("SKU-1")
+	assert.NoError(t, err)
+
+	// directories that aren't products are skipped.
+	for _, name := range []string{"lost+found", "backup"} {
+		assert.NoError(t, os.Mkdir(filepath.Join(dataDir, name), 0o755))
+	}
+
+	products := storage.NewProducts(base, newStorage)
+	assert.NoError(t, loadProducts(dataDir, products))
+	assert.Equal(t, []string{"SKU-1", storage.DefaultSKU}, products.Products())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"reparttask/storage"
+	"time"
+)
+
+// scheduler applies the scheduled pack changes of every product once they are due.
+// Orders already use a due change before it is applied, the scheduler makes it the stored
+// pack set, so that the listeners of the changes and the history follow.
+type scheduler struct {
+	products storage.Catalog
+}
+
+// run applies the due changes on every tick, until ctx is done or the ticks are closed.
+func (s scheduler) run(ctx context.Context, ticks <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-ticks:
+			if !ok {
+				return
+			}
+
+			s.apply(ctx)
+		}
+	}
+}
+
+// apply applies the due changes of every product, a failing product doesn't hold back the others.
+func (s scheduler) apply(ctx context.Context) {
+	for _, sku := range s.products.Products() {
+		db, ok := s.products.Lookup(sku)
+		if !ok {
+			continue
+		}
+
+		applied, err := db.ApplySchedules(ctx)
+		if err != nil {
+			log.Printf("applying the schedules of %q: %v", sku, err)
+			continue
+		}
+
+		if applied > 0 {
+			log.Printf("applied %d scheduled changes of %q", applied, sku)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	clock := func() time.Time { return now }
+
+	base := memory.NewMemDB()
+	base.SetClock(clock)
+	db := storage.NewObserved(base)
+
+	changes := 0
+	db.OnChange(func() { changes++ })
+
+	products := storage.NewProducts(db, func(string) (storage.Storage, error) {
+		product := memory.NewMemDB()
+		product.SetClock(clock)
+		return product, nil
+	})
+
+	other, err := products.Product("SKU-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 250}}))
+	_, err = db.SchedulePacks(ctx, []storage.Pack{{Size: 300}}, start.Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = other.SchedulePacks(ctx, []storage.Pack{{Size: 23}}, start.Add(2*time.Hour))
+	assert.NoError(t, err)
+
+	s := scheduler{products: products}
+	tick := func() {
+		ticks := make(chan time.Time, 1)
+		ticks <- now
+		close(ticks)
+		s.run(ctx, ticks)
+	}
+
+	// nothing is due yet.
+	tick()
+	assert.Equal(t, 1, changes)
+
+	now = start.Add(time.Hour)
+	tick()
+	assert.Equal(t, 2, changes)
+
+	packs, err := db.GetPacks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{300}, storage.Sizes(packs))
+
+	packs, err = other.GetPacks(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, packs)
+
+	// every product is checked on each tick.
+	now = start.Add(2 * time.Hour)
+	tick()
+
+	packs, err = other.GetPacks(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{23}, storage.Sizes(packs))
+	assert.Equal(t, 2, changes)
+}
+
+func TestSchedulerStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// a cancelled scheduler returns without waiting for a tick.
+	scheduler{products: storage.NewProducts(memory.NewMemDB(), nil)}.run(ctx, make(chan time.Time))
+}
@@ -1,12 +1,32 @@
 package config
 
-import "github.com/caarlos0/env"
+import (
+	"errors"
+	"github.com/caarlos0/env"
+	"time"
+)
 
 type LambdaConfig struct {
-	Port int `env:"CUSTOM_PORT" envDefault:"8282"`
+	Port          int           `env:"CUSTOM_PORT" envDefault:"8282"`
+	Calculator    string        `env:"CALCULATOR" envDefault:"dp"`
+	BatchLimit    int           `env:"BATCH_LIMIT" envDefault:"10000"`
+	CalcTimeout   time.Duration `env:"CALC_TIMEOUT" envDefault:"10s"`
+	CacheSize     int           `env:"CACHE_SIZE" envDefault:"1024"`
+	PrecomputeMax int           `env:"PRECOMPUTE_MAX" envDefault:"0"`
+	DataDir       string        `env:"DATA_DIR" envDefault:""`
+	ScheduleEvery time.Duration `env:"SCHEDULE_EVERY" envDefault:"1s"`
 }
 
 func ParseConfig() (LambdaConfig, error) {
 	var lc LambdaConfig
-	return lc, env.Parse(&lc)
+	if err := env.Parse(&lc); err != nil {
+		return lc, err
+	}
+
+	// the scheduler ticks every SCHEDULE_EVERY, a ticker can't be built without a positive period.
+	if lc.ScheduleEvery <= 0 {
+		return lc, errors.New("SCHEDULE_EVERY must be a positive duration, eg. 1s")
+	}
+
+	return lc, nil
 }
@@ -0,0 +1,130 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reparttask/service"
+	"reparttask/utils"
+	"runtime"
+	"sync"
+)
+
+// BatchOrder is a single order of a batch, the ID is optional and echoed back.
+type BatchOrder struct {
+	ID       string `json:"id,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+// BatchPayload accepts bare order quantities and/or orders with their ID,
+// bare quantities come first in the response.
+type BatchPayload struct {
+	Quantities []int        `json:"quantities,omitempty"`
+	Orders     []BatchOrder `json:"orders,omitempty"`
+}
+
+// BatchResult holds either the result or the error of a single order.
+type BatchResult struct {
+	ID       string          `json:"id,omitempty"`
+	Quantity int             `json:"quantity"`
+	Result   *service.Result `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// BatchResponse lists the results in the same order as the request.
+type BatchResponse struct {
+	Results []BatchResult `json:"results"`
+}
+
+// toOrders merges the bare quantities and the orders of the payload.
+func (p BatchPayload) toOrders() []BatchOrder {
+	orders := make([]BatchOrder, 0, len(p.Quantities)+len(p.Orders))
+	for _, qty := range p.Quantities {
+		orders = append(orders, BatchOrder{Quantity: qty})
+	}
+
+	return append(orders, p.Orders...)
+}
+
+// handleBatchOrders calculates many orders at once, against the same packs.
+func (h *Handler) handleBatchOrders(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload BatchPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	orders := payload.toOrders()
+	if len(orders) == 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must provide at least one order"})
+		return
+	}
+
+	if h.opts.BatchLimit > 0 && len(orders) > h.opts.BatchLimit {
+		utils.WriteOutput(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("a batch can't contain more than %d orders", h.opts.BatchLimit),
+		})
+		return
+	}
+
+	// packs are read once, so every order is calculated against the same snapshot.
+	req, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+
+	// the timeout applies to the whole batch.
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	results := make([]BatchResult, len(orders))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(runtime.GOMAXPROCS(0), len(orders)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = h.calculateBatchOrder(ctx, req, orders[j])
+			}
+		}()
+	}
+
+	for i := range orders {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	utils.WriteOutput(w, http.StatusOK, BatchResponse{Results: results})
+}
+
+// calculateBatchOrder calculates a single order of a batch.
+func (h *Handler) calculateBatchOrder(ctx context.Context, req orderRequest, order BatchOrder) BatchResult {
+	res := BatchResult{ID: order.ID, Quantity: order.Quantity}
+	if order.Quantity <= 0 {
+		res.Error = "please provide a number greater than zero"
+		return res
+	}
+
+	if err := req.checkShort(order.Quantity); err != nil {
+		res.Error = err.Error()
+		return res
+	}
+
+	req.quantity = order.Quantity
+	result, err := h.run(ctx, req)
+	if err != nil {
+		_, res.Error = calcError(err)
+		return res
+	}
+
+	result = req.describe(result)
+	res.Result = &result
+	return res
+}
@@ -0,0 +1,215 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reparttask/service/dp"
+	"reparttask/storage"
+	"testing"
+)
+
+func TestHandler_handleBatchOrders(t *testing.T) {
+	type testCaseInput struct {
+		packs   []storage.Pack
+		payload string
+	}
+	type expectedResult struct {
+		id       string
+		quantity int
+		want     map[int]int
+		err      string
+	}
+	type testCaseOutput struct {
+		status  int
+		results []expectedResult
+		err     error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	packs := []storage.Pack{{Size: 250}, {Size: 500}, {Size: 1000}, {Size: 2000}, {Size: 5000}}
+	one := 1
+
+	tests := []testCase{
+		{
+			name: "test quantities and orders keep the request order",
+			input: testCaseInput{
+				packs:   packs,
+				payload: `{"quantities":[751,1],"orders":[{"id":"A-1","quantity":12001},{"id":"A-2","quantity":0}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				results: []expectedResult{
+					{quantity: 751, want: map[int]int{1000: 1}},
+					{quantity: 1, want: map[int]int{250: 1}},
+					{id: "A-1", quantity: 12001, want: map[int]int{5000: 2, 2000: 1, 250: 1}},
+					{id: "A-2", quantity: 0, err: "please provide a number greater than zero"},
+				},
+			},
+		},
+		{
+			name: "test calculation error for a single order",
+			input: testCaseInput{
+				packs:   []storage.Pack{{Size: 250, Stock: &one}},
+				payload: `{"quantities":[250,251]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				results: []expectedResult{
+					{quantity: 250, want: map[int]int{250: 1}},
+					{quantity: 251, err: "cannot fulfil order with the available pack stock"},
+				},
+			},
+		},
+		{
+			name: "test batch over the limit, error returned",
+			input: testCaseInput{
+				packs:   packs,
+				payload: `{"quantities":[1,2,3,4,5]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusRequestEntityTooLarge,
+				err:    errors.New("a batch can't contain more than 4 orders"),
+			},
+		},
+		{
+			name: "test empty batch, error returned",
+			input: testCaseInput{
+				packs:   packs,
+				payload: `{}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("you must provide at least one order"),
+			},
+		},
+		{
+			name: "test no packs stored, error returned",
+			input: testCaseInput{
+				packs:   nil,
+				payload: `{"quantities":[1]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("you must first add some packaging sizes"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(NewDbMockWithPacks(tt.input.packs), nil), dp.NewCalc(), nil, Options{BatchLimit: 4})
+
+			req := httptest.NewRequest(http.MethodPost, "/orders/batch", bytes.NewBufferString(tt.input.payload))
+
+			w := httptest.NewRecorder()
+			h.handleBatchOrders(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			var data BatchResponse
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, len(tt.expected.results), len(data.Results))
+			for i, want := range tt.expected.results {
+				got := data.Results[i]
+				assert.Equal(t, want.id, got.ID)
+				assert.Equal(t, want.quantity, got.Quantity)
+				assert.Equal(t, want.err, got.Error)
+
+				if want.want == nil {
+					assert.Nil(t, got.Result)
+					continue
+				}
+
+				assert.Equal(t, want.want, got.Result.Map())
+			}
+		})
+	}
+}
+
+func TestHandler_handleBatchOrdersShort(t *testing.T) {
+	h := NewHandler(storage.NewProducts(NewDbMock([]int{250}), nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/orders/batch?mode=allow-short&max_short=100", bytes.NewBufferString(`{"quantities":[100,101]}`))
+
+	w := httptest.NewRecorder()
+	h.handleBatchOrders(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var data BatchResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+
+	// an order the tolerance could miss entirely fails on its own.
+	assert.Equal(t, "max_short must be less than the number of items", data.Results[0].Error)
+	assert.Equal(t, map[int]int{250: 1}, data.Results[1].Result.Map())
+}
+
+func TestHandler_handleBatchOrdersParallel(t *testing.T) {
+	const orders = 1000
+
+	packs := []storage.Pack{{Size: 23}, {Size: 31}, {Size: 53}}
+	payload := BatchPayload{}
+	for i := 0; i < orders; i++ {
+		payload.Quantities = append(payload.Quantities, quantityFor(i))
+	}
+
+	h := NewHandler(storage.NewProducts(NewDbMockWithPacks(packs), nil), dp.NewCalc(), nil, Options{BatchLimit: orders})
+
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, "/orders/batch", bytes.NewBuffer(body))
+
+	w := httptest.NewRecorder()
+	h.handleBatchOrders(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var data BatchResponse
+	err := json.Unmarshal(w.Body.Bytes(), &data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, orders, len(data.Results))
+	for i, got := range data.Results {
+		want, err := dp.NewCalc().CalculatePacks(context.Background(), storage.Sizes(packs), quantityFor(i))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !assert.Equal(t, want.Map(), got.Result.Map(), "order %d", i) {
+			return
+		}
+	}
+}
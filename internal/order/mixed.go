@@ -0,0 +1,84 @@
+package order
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reparttask/service"
+	"reparttask/utils"
+)
+
+// MixedOrder is the quantity ordered for a single product of a mixed order.
+type MixedOrder struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+// MixedPayload lists the products of a mixed order.
+type MixedPayload struct {
+	Orders []MixedOrder `json:"orders"`
+}
+
+// ProductPlan holds either the result or the error of a single product.
+type ProductPlan struct {
+	SKU      string          `json:"sku"`
+	Quantity int             `json:"quantity"`
+	Result   *service.Result `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// MixedResponse lists one plan per product, in the same order as the request.
+type MixedResponse struct {
+	Plans []ProductPlan `json:"plans"`
+}
+
+// handleMixedOrder calculates an order covering several products,
+// every product is packed with its own packs.
+func (h *Handler) handleMixedOrder(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload MixedPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if len(payload.Orders) == 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must provide at least one order"})
+		return
+	}
+
+	if h.opts.BatchLimit > 0 && len(payload.Orders) > h.opts.BatchLimit {
+		utils.WriteOutput(w, http.StatusRequestEntityTooLarge, map[string]string{
+			"error": fmt.Sprintf("a batch can't contain more than %d orders", h.opts.BatchLimit),
+		})
+		return
+	}
+
+	// the timeout applies to the whole order.
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	plans := make([]ProductPlan, len(payload.Orders))
+	for i, order := range payload.Orders {
+		plans[i] = ProductPlan{SKU: order.SKU, Quantity: order.Quantity}
+
+		db, ok := h.products.Lookup(order.SKU)
+		if !ok {
+			plans[i].Error = fmt.Sprintf("product %q not found", order.SKU)
+			continue
+		}
+
+		req, _, err := h.newRequest(r, db)
+		if err != nil {
+			plans[i].Error = err.Error()
+			continue
+		}
+
+		res := h.calculateBatchOrder(ctx, req, BatchOrder{Quantity: order.Quantity})
+		plans[i].Result, plans[i].Error = res.Result, res.Error
+	}
+
+	utils.WriteOutput(w, http.StatusOK, MixedResponse{Plans: plans})
+}
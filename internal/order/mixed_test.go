@@ -0,0 +1,193 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"reparttask/service/dp"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"testing"
+)
+
+// newProducts returns a catalog with the given packs for every product.
+func newProducts(t *testing.T, packs map[string][]int) *storage.Products {
+	products := storage.NewProducts(memory.NewMemDB(), func(string) (storage.Storage, error) { return memory.NewMemDB(), nil })
+	for sku, sizes := range packs {
+		db, err := products.Product(sku)
+		assert.NoError(t, err)
+
+		for _, size := range sizes {
+			assert.NoError(t, db.AddPacks(context.Background(), []storage.Pack{{Size: size}}))
+		}
+	}
+
+	return products
+}
+
+func TestHandler_handleGetProductOrder(t *testing.T) {
+	products := newProducts(t, map[string][]int{
+		storage.DefaultSKU: {250, 500, 1000, 2000, 5000},
+		"SKU-1":            {23, 31, 53},
+	})
+
+	router := http.NewServeMux()
+	NewHandler(products, dp.NewCalc(), nil, Options{}).RegisterRoutes(router)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   any
+	}{
+		{
+			name:   "test product order",
+			path:   "/products/SKU-1/order/100",
+			status: http.StatusOK,
+			want:   map[string]any{"23": 3.0, "31": 1.0},
+		},
+		{
+			name:   "test default product order",
+			path:   "/products/default/order/12001",
+			status: http.StatusOK,
+			want:   map[string]any{"5000": 2.0, "2000": 1.0, "250": 1.0},
+		},
+		{
+			name:   "test unscoped order uses the default product",
+			path:   "/order/12001",
+			status: http.StatusOK,
+			want:   map[string]any{"5000": 2.0, "2000": 1.0, "250": 1.0},
+		},
+		{
+			name:   "test unknown product, error returned",
+			path:   "/products/SKU-2/order/100",
+			status: http.StatusNotFound,
+			want:   map[string]any{"error": `product "SKU-2" not found`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+
+			var got map[string]any
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHandler_handleConfirmProductOrder(t *testing.T) {
+	products := newProducts(t, map[string][]int{"SKU-1": {}})
+	db, _ := products.Lookup("SKU-1")
+	stock := 1
+	assert.NoError(t, db.AddPacks(context.Background(), []storage.Pack{{Size: 10, Stock: &stock}}))
+
+	router := http.NewServeMux()
+	NewHandler(products, dp.NewCalc(), nil, Options{}).RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products/SKU-1/order/10/confirm", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// the stock of that product only is taken.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/products/SKU-1/order/10/confirm", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestHandler_handleMixedOrder(t *testing.T) {
+	products := newProducts(t, map[string][]int{
+		storage.DefaultSKU: {250, 500, 1000, 2000, 5000},
+		"SKU-1":            {23, 31, 53},
+		"SKU-2":            {},
+	})
+
+	h := NewHandler(products, dp.NewCalc(), nil, Options{BatchLimit: 3})
+
+	tests := []struct {
+		name    string
+		payload string
+		status  int
+		want    []ProductPlan
+		err     string
+	}{
+		{
+			name:    "test one plan per product",
+			payload: `{"orders":[{"sku":"SKU-1","quantity":100},{"sku":"default","quantity":12001},{"sku":"SKU-3","quantity":1}]}`,
+			status:  http.StatusOK,
+			want: []ProductPlan{
+				{SKU: "SKU-1", Quantity: 100},
+				{SKU: "default", Quantity: 12001},
+				{SKU: "SKU-3", Quantity: 1, Error: `product "SKU-3" not found`},
+			},
+		},
+		{
+			name:    "test product errors are reported per product",
+			payload: `{"orders":[{"sku":"SKU-2","quantity":1},{"sku":"SKU-1","quantity":0}]}`,
+			status:  http.StatusOK,
+			want: []ProductPlan{
+				{SKU: "SKU-2", Quantity: 1, Error: "you must first add some packaging sizes"},
+				{SKU: "SKU-1", Quantity: 0, Error: "please provide a number greater than zero"},
+			},
+		},
+		{
+			name:    "test empty order, error returned",
+			payload: `{"orders":[]}`,
+			status:  http.StatusBadRequest,
+			err:     "you must provide at least one order",
+		},
+		{
+			name:    "test too many orders, error returned",
+			payload: `{"orders":[{"sku":"a","quantity":1},{"sku":"b","quantity":1},{"sku":"c","quantity":1},{"sku":"d","quantity":1}]}`,
+			status:  http.StatusRequestEntityTooLarge,
+			err:     "a batch can't contain more than 3 orders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/products/orders", bytes.NewBufferString(tt.payload))
+
+			w := httptest.NewRecorder()
+			h.handleMixedOrder(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+
+			if tt.err != "" {
+				e := map[string]string{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+				assert.Equal(t, tt.err, e["error"])
+				return
+			}
+
+			var resp MixedResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, len(tt.want), len(resp.Plans))
+
+			for i, want := range tt.want {
+				got := resp.Plans[i]
+				assert.Equal(t, want.SKU, got.SKU)
+				assert.Equal(t, want.Quantity, got.Quantity)
+				assert.Equal(t, want.Error, got.Error)
+				assert.Equal(t, want.Error == "", got.Result != nil)
+			}
+		})
+	}
+
+	// every product is packed with its own packs.
+	req := httptest.NewRequest(http.MethodPost, "/products/orders", bytes.NewBufferString(tests[0].payload))
+	w := httptest.NewRecorder()
+	h.handleMixedOrder(w, req)
+
+	var resp MixedResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[int]int{23: 3, 31: 1}, resp.Plans[0].Result.Map())
+	assert.Equal(t, map[int]int{5000: 2, 2000: 1, 250: 1}, resp.Plans[1].Result.Map())
+}
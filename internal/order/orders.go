@@ -0,0 +1,206 @@
+package order
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reparttask/storage"
+	"reparttask/utils"
+	"strconv"
+	"time"
+)
+
+// orderParams are the query parameters stored with an order, the ones changing its calculation.
+var orderParams = []string{"objective", "max_packs", "surplus_cost", "mode", "max_short", "max_surplus", "version", "at"}
+
+// Page sizes of the order list.
+const (
+	defaultOrdersLimit = 50
+	maxOrdersLimit     = 500
+)
+
+// OrderPayload is an order to calculate and store, the default product is used when no SKU is given.
+// The objective, the mode and the version of the packs are read from the query like for GET /order/{items}.
+type OrderPayload struct {
+	SKU      string `json:"sku,omitempty"`
+	Quantity int    `json:"quantity"`
+}
+
+// OrdersResponse is a page of the stored orders, newest first.
+type OrdersResponse struct {
+	Orders []storage.Order `json:"orders"`
+	// Total is the number of orders matching the filter, across every page.
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// handlePlaceOrder calculates an order and stores it together with its result and the version of the packs used.
+func (h *Handler) handlePlaceOrder(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload OrderPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if payload.Quantity <= 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "please provide a number greater than zero"})
+		return
+	}
+
+	if payload.SKU == "" {
+		payload.SKU = storage.DefaultSKU
+	}
+
+	db, ok := h.products.Lookup(payload.SKU)
+	if !ok {
+		utils.WriteStorageError(w, storage.Errorf(storage.ErrNotFound, "product %q not found", payload.SKU))
+		return
+	}
+
+	// a due schedule is applied first, so the order refers to a version of the history.
+	if _, err := db.ApplySchedules(utils.Context(r)); err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	req, status, err := h.newRequest(r, db)
+	if err != nil {
+		utils.WriteOutput(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := req.checkShort(payload.Quantity); err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	req.quantity = payload.Quantity
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	result, err := h.run(ctx, req)
+	if err != nil {
+		writeCalcError(w, err)
+		return
+	}
+
+	var params map[string]string
+	query := r.URL.Query()
+	for _, key := range orderParams {
+		if !query.Has(key) {
+			continue
+		}
+
+		if params == nil {
+			params = map[string]string{}
+		}
+		params[key] = query.Get(key)
+	}
+
+	// the result is stored as it is returned, whatever the calculator.
+	encoded, err := json.Marshal(req.describe(result))
+	if err != nil {
+		utils.WriteOutput(w, http.StatusInternalServerError, map[string]string{"error": "an error has occurred"})
+		return
+	}
+
+	order, err := h.opts.Orders.AddOrder(r.Context(), storage.Order{
+		Actor:    r.Header.Get(utils.ActorHeader),
+		SKU:      payload.SKU,
+		Quantity: payload.Quantity,
+		Params:   params,
+		Version:  req.version,
+		Result:   encoded,
+	})
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/orders/%d", order.ID))
+	utils.WriteOutput(w, http.StatusCreated, order)
+}
+
+// handleGetStoredOrder returns a stored order.
+func (h *Handler) handleGetStoredOrder(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "id must be a number greater than zero"})
+		return
+	}
+
+	order, err := h.opts.Orders.GetOrder(r.Context(), id)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, order)
+}
+
+// handleListOrders returns a page of the stored orders, filtered by product and creation time.
+func (h *Handler) handleListOrders(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseOrderFilter(r)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	orders, total, err := h.opts.Orders.ListOrders(r.Context(), filter)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	if orders == nil {
+		orders = []storage.Order{}
+	}
+
+	utils.WriteOutput(w, http.StatusOK, OrdersResponse{Orders: orders, Total: total, Offset: filter.Offset, Limit: filter.Limit})
+}
+
+// parseOrderFilter reads the filter and the page of the order list from the query parameters.
+func parseOrderFilter(r *http.Request) (storage.OrderFilter, error) {
+	query := r.URL.Query()
+
+	filter := storage.OrderFilter{SKU: query.Get("sku"), Limit: defaultOrdersLimit}
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := query.Get(bound.name)
+		if value == "" {
+			continue
+		}
+
+		var err error
+		*bound.t, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC 3339 time, eg. 2026-01-02T15:04:05Z", bound.name)
+		}
+	}
+
+	if value := query.Get("offset"); value != "" {
+		nr, err := strconv.Atoi(value)
+		if err != nil || nr < 0 {
+			return filter, errors.New("offset must be a number greater than or equal to zero")
+		}
+
+		filter.Offset = nr
+	}
+
+	if value := query.Get("limit"); value != "" {
+		nr, err := strconv.Atoi(value)
+		if err != nil || nr <= 0 || nr > maxOrdersLimit {
+			return filter, fmt.Errorf("limit must be a number between 1 and %d", maxOrdersLimit)
+		}
+
+		filter.Limit = nr
+	}
+
+	return filter, nil
+}
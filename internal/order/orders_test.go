@@ -0,0 +1,317 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"reparttask/service"
+	"reparttask/service/dp"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newOrdersRouter returns a router storing the orders of the given products.
+func newOrdersRouter(t *testing.T, packs map[string][]int) (*http.ServeMux, *memory.OrderDB) {
+	orders := memory.NewOrderDB()
+	orders.SetClock(func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) })
+
+	router := http.NewServeMux()
+	NewHandler(newProducts(t, packs), dp.NewCalc(), nil, Options{Orders: orders}).RegisterRoutes(router)
+	return router, orders
+}
+
+func placeOrder(t *testing.T, router *http.ServeMux, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("X-Actor", "alice")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandler_handlePlaceOrder(t *testing.T) {
+	router, _ := newOrdersRouter(t, map[string][]int{
+		storage.DefaultSKU: {250, 500, 1000, 2000, 5000},
+		"SKU-1":            {23, 31, 53},
+		"SKU-2":            {},
+	})
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		want   map[string]any
+	}{
+		{
+			name:   "test default product order",
+			path:   "/orders",
+			body:   `{"quantity": 12001}`,
+			status: http.StatusCreated,
+			want: map[string]any{
+				"id":         1.0,
+				"created_at": "2026-10-01T12:00:00Z",
+				"actor":      "alice",
+				"sku":        storage.DefaultSKU,
+				"quantity":   12001.0,
+				"version":    5.0,
+			},
+		},
+		{
+			name:   "test product order with params",
+			path:   "/orders?mode=exact&utm_source=mail",
+			body:   `{"sku": "SKU-1", "quantity": 100}`,
+			status: http.StatusCreated,
+			want: map[string]any{
+				"id":         2.0,
+				"created_at": "2026-10-01T12:00:00Z",
+				"actor":      "alice",
+				"sku":        "SKU-1",
+				"quantity":   100.0,
+				"params":     map[string]any{"mode": "exact"},
+				"version":    3.0,
+			},
+		},
+		{
+			name:   "test invalid body, error returned",
+			path:   "/orders",
+			body:   `{"quantity": "ten"}`,
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "test zero quantity, error returned",
+			path:   "/orders",
+			body:   `{"quantity": 0}`,
+			status: http.StatusBadRequest,
+			want:   map[string]any{"error": "please provide a number greater than zero"},
+		},
+		{
+			name:   "test unknown product, error returned",
+			path:   "/orders",
+			body:   `{"sku": "SKU-3", "quantity": 10}`,
+			status: http.StatusNotFound,
+			want:   map[string]any{"error": `product "SKU-3" not found`},
+		},
+		{
+			name:   "test product without packs, error returned",
+			path:   "/orders",
+			body:   `{"sku": "SKU-2", "quantity": 10}`,
+			status: http.StatusBadRequest,
+			want:   map[string]any{"error": "you must first add some packaging sizes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := placeOrder(t, router, tt.path, tt.body)
+			assert.Equal(t, tt.status, w.Code)
+
+			var got map[string]any
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			if tt.want == nil {
+				return
+			}
+
+			if w.Code == http.StatusCreated {
+				assert.Equal(t, fmt.Sprintf("/orders/%v", got["id"]), w.Header().Get("Location"))
+				assert.NotEmpty(t, got["result"])
+				delete(got, "result")
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHandler_handlePlaceOrderSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	db := memory.NewMemDB()
+	db.SetClock(func() time.Time { return now })
+
+	if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 250}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SchedulePacks(context.Background(), []storage.Pack{{Size: 300}}, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schedule is due but the scheduler didn't run yet.
+	now = start.Add(2 * time.Hour)
+
+	orders := memory.NewOrderDB()
+	router := http.NewServeMux()
+	NewHandler(storage.NewProducts(db, nil), dp.NewCalc(), nil, Options{Orders: orders}).RegisterRoutes(router)
+
+	w := placeOrder(t, router, "/orders", `{"quantity": 300}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var got storage.Order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	assert.Equal(t, 2, got.Version)
+
+	var result service.Result
+	assert.NoError(t, json.Unmarshal(got.Result, &result))
+	assert.Equal(t, map[int]int{300: 1}, result.Map())
+}
+
+func TestHandler_handleGetStoredOrder(t *testing.T) {
+	router, _ := newOrdersRouter(t, map[string][]int{storage.DefaultSKU: {250, 500}})
+
+	w := placeOrder(t, router, "/orders", `{"quantity": 750}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		want   string
+	}{
+		{
+			name:   "test stored order",
+			path:   "/orders/1",
+			status: http.StatusOK,
+			want:   w.Body.String(),
+		},
+		{
+			name:   "test unknown order, error returned",
+			path:   "/orders/2",
+			status: http.StatusNotFound,
+			want:   `{"error":"order 2 not found"}`,
+		},
+		{
+			name:   "test invalid id, error returned",
+			path:   "/orders/first",
+			status: http.StatusBadRequest,
+			want:   `{"error":"id must be a number greater than zero"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.JSONEq(t, tt.want, w.Body.String())
+		})
+	}
+}
+
+func TestHandler_handleListOrders(t *testing.T) {
+	router, orders := newOrdersRouter(t, map[string][]int{
+		storage.DefaultSKU: {250, 500},
+		"SKU-1":            {23, 31, 53},
+	})
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	orders.SetClock(func() time.Time { return now })
+
+	for _, body := range []string{`{"quantity": 250}`, `{"sku": "SKU-1", "quantity": 100}`, `{"quantity": 500}`} {
+		assert.Equal(t, http.StatusCreated, placeOrder(t, router, "/orders", body).Code)
+		now = now.Add(time.Hour)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+		ids    []int
+		total  int
+		error  string
+	}{
+		{
+			name:   "test every order, newest first",
+			path:   "/orders",
+			status: http.StatusOK,
+			ids:    []int{3, 2, 1},
+			total:  3,
+		},
+		{
+			name:   "test orders of a product",
+			path:   "/orders?sku=default",
+			status: http.StatusOK,
+			ids:    []int{3, 1},
+			total:  2,
+		},
+		{
+			name:   "test orders in a time range",
+			path:   "/orders?from=2026-10-01T13:00:00Z&to=2026-10-01T14:00:00Z",
+			status: http.StatusOK,
+			ids:    []int{2},
+			total:  1,
+		},
+		{
+			name:   "test page of the orders",
+			path:   "/orders?offset=1&limit=1",
+			status: http.StatusOK,
+			ids:    []int{2},
+			total:  3,
+		},
+		{
+			name:   "test page after the last order",
+			path:   "/orders?offset=5",
+			status: http.StatusOK,
+			ids:    []int{},
+			total:  3,
+		},
+		{
+			name:   "test invalid from, error returned",
+			path:   "/orders?from=yesterday",
+			status: http.StatusBadRequest,
+			error:  "from must be an RFC 3339 time, eg. 2026-01-02T15:04:05Z",
+		},
+		{
+			name:   "test invalid offset, error returned",
+			path:   "/orders?offset=-1",
+			status: http.StatusBadRequest,
+			error:  "offset must be a number greater than or equal to zero",
+		},
+		{
+			name:   "test limit too large, error returned",
+			path:   "/orders?limit=501",
+			status: http.StatusBadRequest,
+			error:  "limit must be a number between 1 and 500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			assert.Equal(t, tt.status, w.Code)
+
+			if tt.error != "" {
+				assert.JSONEq(t, fmt.Sprintf(`{"error":%q}`, tt.error), w.Body.String())
+				return
+			}
+
+			var got OrdersResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			ids := []int{}
+			for _, order := range got.Orders {
+				ids = append(ids, order.ID)
+			}
+			assert.Equal(t, tt.ids, ids)
+			assert.Equal(t, tt.total, got.Total)
+		})
+	}
+}
+
+func TestHandler_ordersWithoutStore(t *testing.T) {
+	router := http.NewServeMux()
+	NewHandler(newProducts(t, map[string][]int{storage.DefaultSKU: {250}}), dp.NewCalc(), nil, Options{}).RegisterRoutes(router)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/orders/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -1,51 +1,516 @@
 package order
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"reparttask/service"
+	"reparttask/service/hierarchy"
 	"reparttask/storage"
 	"reparttask/utils"
 	"strconv"
+	"time"
 )
 
+// Order modes selected with the mode query parameter.
+const (
+	// modeExact only accepts packings without any surplus.
+	modeExact = "exact"
+	// modeAllowShort accepts packings up to max_short items below the order.
+	modeAllowShort = "allow-short"
+	// modeMaxSurplus only accepts packings up to max_surplus items above the order.
+	modeMaxSurplus = "max-surplus"
+)
+
+// packLevel names the innermost level of a nested packing plan.
+const packLevel = "pack"
+
+// defaultAlternatives is the number of alternatives returned when k is not provided.
+const defaultAlternatives = 3
+
+// AlternativesResponse lists the best packings, the first one is the packing
+// returned by the order endpoints.
+type AlternativesResponse struct {
+	Alternatives []service.Result `json:"alternatives"`
+	// Explanation tells why the first packing beat the second one, only set in debug mode.
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// Options holds the configurable limits and dependencies of the order endpoints.
+type Options struct {
+	// BatchLimit is the largest number of orders accepted by a single batch request.
+	BatchLimit int
+	// Timeout limits how long a single request may calculate, 0 means no limit.
+	Timeout time.Duration
+	// Orders stores the orders placed, the order history endpoints are only served when it is set.
+	Orders storage.OrderStore
+}
+
 type Handler struct {
-	db   storage.Storage
-	calc service.Calculator
+	products   storage.Catalog
+	calc       service.Calculator
+	objectives *service.Registry
+	opts       Options
 }
 
-func NewHandler(db storage.Storage, calc service.Calculator) *Handler {
-	return &Handler{db: db, calc: calc}
+func NewHandler(products storage.Catalog, calc service.Calculator, objectives *service.Registry, opts Options) *Handler {
+	return &Handler{products: products, calc: calc, objectives: objectives, opts: opts}
 }
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
 	router.HandleFunc("GET /order/{items}", h.handleGetOrder)
+	router.HandleFunc("GET /v2/order/{items}", h.handleGetOrderV2)
+	router.HandleFunc("POST /order/{items}/confirm", h.handleConfirmOrder)
+	router.HandleFunc("GET /order/{items}/alternatives", h.handleGetAlternatives)
+	router.HandleFunc("POST /orders/batch", h.handleBatchOrders)
+
+	if h.opts.Orders != nil {
+		router.HandleFunc("POST /orders", h.handlePlaceOrder)
+		router.HandleFunc("GET /orders", h.handleListOrders)
+		router.HandleFunc("GET /orders/{id}", h.handleGetStoredOrder)
+	}
+
+	// orders for a product, the ones above use the default product.
+	router.HandleFunc("GET /products/{sku}/order/{items}", h.handleGetOrder)
+	router.HandleFunc("GET /products/{sku}/v2/order/{items}", h.handleGetOrderV2)
+	router.HandleFunc("POST /products/{sku}/order/{items}/confirm", h.handleConfirmOrder)
+	router.HandleFunc("POST /products/orders", h.handleMixedOrder)
 }
 
+// handleGetOrder returns the packing as a pack size => count map,
+// or the packing of every level of the hierarchy when nested is set.
 func (h *Handler) handleGetOrder(w http.ResponseWriter, r *http.Request) {
+	req, result, ok := h.calculate(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("nested") != "true" {
+		utils.WriteOutput(w, http.StatusOK, result.Map())
+		return
+	}
+
+	levels, err := req.db.GetLevels(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	// the levels above the packs always follow the default rules.
+	plan, err := hierarchy.NewCalc(h.calc).Plan(ctx, packLevel, result, levels)
+	if err != nil {
+		writeCalcError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, plan)
+}
+
+// handleGetOrderV2 returns the full calculation result.
+func (h *Handler) handleGetOrderV2(w http.ResponseWriter, r *http.Request) {
+	_, result, ok := h.calculate(w, r)
+	if !ok {
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, result)
+}
+
+// handleConfirmOrder calculates the order and takes the packs used out of stock.
+func (h *Handler) handleConfirmOrder(w http.ResponseWriter, r *http.Request) {
+	// the packs of an older version may no longer be stored, so they can't be reserved.
+	if query := r.URL.Query(); query.Has("version") || query.Has("at") {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "orders can only be confirmed against the current packs"})
+		return
+	}
+
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	// the packs are reserved in the stored set, a due schedule must replace it first.
+	if _, err := db.ApplySchedules(utils.Context(r)); err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	req, result, ok := h.calculate(w, r)
+	if !ok {
+		return
+	}
+
+	// the stock may have changed since the calculation, the reservation
+	// checks it again atomically so the same packs can't be used twice.
+	err := req.db.ReservePacks(r.Context(), result.Map())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusCreated, result)
+}
+
+// handleGetAlternatives returns the k best packings ranked by the selected objective.
+func (h *Handler) handleGetAlternatives(w http.ResponseWriter, r *http.Request) {
+	k := defaultAlternatives
+	if value := r.URL.Query().Get("k"); value != "" {
+		nr, err := strconv.Atoi(value)
+		if err != nil || nr <= 0 || nr > service.MaxAlternatives {
+			utils.WriteOutput(w, http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("k must be a number between 1 and %d", service.MaxAlternatives),
+			})
+			return
+		}
+
+		k = nr
+	}
+
+	req, ok := h.prepare(w, r)
+	if !ok {
+		return
+	}
+
+	calc, ok := req.calc.(service.AlternativesCalculator)
+	if !ok {
+		writeCalcError(w, service.ErrAlternativesNotSupported)
+		return
+	}
+
+	// alternatives are ranked without stock limits, so they can't be offered for limited stock.
+	if len(storage.Stock(req.packs)) > 0 {
+		writeCalcError(w, service.ErrStockNotSupported)
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	results, err := calc.CalculateAlternatives(ctx, storage.Sizes(req.packs), req.quantity, k)
+	if err != nil {
+		writeCalcError(w, err)
+		return
+	}
+
+	resp := AlternativesResponse{Alternatives: make([]service.Result, len(results))}
+	for i, result := range results {
+		resp.Alternatives[i] = req.describe(result)
+	}
+
+	if r.URL.Query().Get("debug") == "true" && len(resp.Alternatives) > 1 {
+		resp.Explanation = calc.Explain(resp.Alternatives[0], resp.Alternatives[1])
+	}
+
+	utils.WriteOutput(w, http.StatusOK, resp)
+}
+
+// orderRequest holds everything needed to calculate an order.
+type orderRequest struct {
+	db       storage.Storage
+	quantity int
+	packs    []storage.Pack
+	params   service.Params
+	calc     service.Calculator
+	// version is the version of the packs, 0 for a scheduled change not applied yet.
+	version int
+	// maxShort is the number of items the packing may fall short of the order, in allow-short mode.
+	maxShort int
+}
+
+// checkShort returns the error reported when the packing may fall short of the whole order,
+// an empty packing would then be accepted.
+func (req orderRequest) checkShort(quantity int) error {
+	if req.maxShort >= quantity {
+		return errors.New("max_short must be less than the number of items")
+	}
+
+	return nil
+}
+
+// calculate validates the request and runs the calculator,
+// on failure the error response is already written.
+func (h *Handler) calculate(w http.ResponseWriter, r *http.Request) (orderRequest, service.Result, bool) {
+	req, ok := h.prepare(w, r)
+	if !ok {
+		return orderRequest{}, service.Result{}, false
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+
+	result, err := h.run(ctx, req)
+	if err != nil {
+		writeCalcError(w, err)
+		return orderRequest{}, service.Result{}, false
+	}
+
+	return req, req.describe(result), true
+}
+
+// describe adds the costs and the pack info of the stored packs to a result.
+func (req orderRequest) describe(result service.Result) service.Result {
+	info := make(map[int]service.PackInfo, len(req.packs))
+	for _, p := range req.packs {
+		info[p.Size] = service.PackInfo{
+			Name:       p.Name,
+			Barcode:    p.Barcode,
+			Dimensions: p.Dimensions,
+			TareWeight: p.TareWeight,
+		}
+	}
+
+	return result.WithCosts(req.params.Costs, req.params.SurplusCost).WithPackInfo(info)
+}
+
+// prepare validates the request and selects the calculator,
+// on failure the error response is already written.
+func (h *Handler) prepare(w http.ResponseWriter, r *http.Request) (orderRequest, bool) {
 	items := r.PathValue("items")
 	if items == "" {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must provide a number of items"})
-		return
+		return orderRequest{}, false
 	}
 
 	nr, err := strconv.Atoi(items)
 	if err != nil {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "please provide a numeric value"})
-		return
+		return orderRequest{}, false
 	}
 
 	if nr <= 0 {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "please provide a number greater than zero"})
-		return
+		return orderRequest{}, false
+	}
+
+	req, ok := h.load(w, r)
+	if !ok {
+		return orderRequest{}, false
+	}
+
+	if err := req.checkShort(nr); err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return orderRequest{}, false
+	}
+
+	req.quantity = nr
+	return req, true
+}
+
+// load reads the stored packs of the requested product and selects the calculator
+// for the request, on failure the error response is already written.
+func (h *Handler) load(w http.ResponseWriter, r *http.Request) (orderRequest, bool) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return orderRequest{}, false
+	}
+
+	req, status, err := h.newRequest(r, db)
+	if err != nil {
+		utils.WriteOutput(w, status, map[string]string{"error": err.Error()})
+		return orderRequest{}, false
+	}
+
+	return req, true
+}
+
+// newRequest reads the stored packs of db and selects the calculator for the request,
+// on failure it returns the status code reported with the error.
+func (h *Handler) newRequest(r *http.Request, db storage.Storage) (orderRequest, int, error) {
+	set, status, err := readPackSet(r, db)
+	if err != nil {
+		return orderRequest{}, status, err
 	}
+	packs := set.Packs
 
-	packs := h.db.GetPacks()
 	if len(packs) == 0 {
-		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must first add some packaging sizes"})
-		return
+		return orderRequest{}, http.StatusBadRequest, errors.New("you must first add some packaging sizes")
 	}
 
-	result := h.calc.CalculatePacks(packs, nr)
+	params, err := parseParams(r)
+	if err != nil {
+		return orderRequest{}, http.StatusBadRequest, err
+	}
+	params.Costs = storage.Costs(packs)
 
-	utils.WriteOutput(w, http.StatusOK, result)
+	calc, err := h.calculator(r.URL.Query().Get("objective"), params)
+	if err != nil {
+		return orderRequest{}, http.StatusBadRequest, err
+	}
+
+	tolerance, err := parseMode(r)
+	if err != nil {
+		return orderRequest{}, http.StatusBadRequest, err
+	}
+
+	maxShort := 0
+	if tolerance != nil {
+		maxShort = tolerance.MaxShort
+		tolerant, ok := calc.(service.TolerantCalculator)
+		if !ok {
+			status, _ := calcError(service.ErrModeNotSupported)
+			return orderRequest{}, status, service.ErrModeNotSupported
+		}
+
+		calc, err = tolerant.WithTolerance(*tolerance)
+		if err != nil {
+			status, msg := calcError(err)
+			return orderRequest{}, status, errors.New(msg)
+		}
+	}
+
+	return orderRequest{db: db, version: set.Version, packs: packs, params: params, calc: calc, maxShort: maxShort}, http.StatusOK, nil
+}
+
+// readPackSet returns the version of the packs of db active now, at the instant in the query, or the version
+// in the query to price an order as it was then, on failure it returns the status code reported with the error.
+func readPackSet(r *http.Request, db storage.Storage) (storage.PackSet, int, error) {
+	query := r.URL.Query()
+	if query.Has("version") && query.Has("at") {
+		return storage.PackSet{}, http.StatusBadRequest, errors.New("version and at can't be used together")
+	}
+
+	var at time.Time
+	load := func(ctx context.Context) (storage.PackSet, error) { return db.ActivePackSet(ctx, at) }
+
+	if value := query.Get("at"); value != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return storage.PackSet{}, http.StatusBadRequest, errors.New("at must be an RFC 3339 time, eg. 2026-01-02T15:04:05Z")
+		}
+	}
+
+	if value := query.Get("version"); value != "" {
+		nr, err := strconv.Atoi(value)
+		if err != nil || nr <= 0 {
+			return storage.PackSet{}, http.StatusBadRequest, errors.New("version must be a number greater than zero")
+		}
+
+		load = func(ctx context.Context) (storage.PackSet, error) { return db.PackSet(ctx, nr) }
+	}
+
+	set, err := load(r.Context())
+	if err != nil {
+		status, msg := utils.StorageError(err)
+		return storage.PackSet{}, status, errors.New(msg)
+	}
+
+	return set, http.StatusOK, nil
+}
+
+// writeCalcError translates a calculation error into the error response.
+func writeCalcError(w http.ResponseWriter, err error) {
+	status, msg := calcError(err)
+	utils.WriteOutput(w, status, map[string]string{"error": msg})
+}
+
+// calcError returns the status code and message reported for a calculation error,
+// the storage errors a calculator may return, like storage.ErrInsufficientStock, and a done
+// context are reported by utils.StorageError.
+func calcError(err error) (int, string) {
+	switch {
+	case errors.Is(err, service.ErrNoSolution), errors.Is(err, service.ErrTooLarge), errors.Is(err, service.ErrStockNotSupported),
+		errors.Is(err, service.ErrAlternativesNotSupported), errors.Is(err, service.ErrModeNotSupported):
+		return http.StatusUnprocessableEntity, err.Error()
+	default:
+		return utils.StorageError(err)
+	}
+}
+
+// context returns the request context limited by the configured timeout.
+func (h *Handler) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.opts.Timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+
+	return context.WithTimeout(r.Context(), h.opts.Timeout)
+}
+
+// run calculates the order, respecting the stock when some pack sizes are limited.
+func (h *Handler) run(ctx context.Context, req orderRequest) (service.Result, error) {
+	stock := storage.Stock(req.packs)
+	if len(stock) == 0 {
+		return req.calc.CalculatePacks(ctx, storage.Sizes(req.packs), req.quantity)
+	}
+
+	bounded, ok := req.calc.(service.BoundedCalculator)
+	if !ok {
+		return service.Result{}, service.ErrStockNotSupported
+	}
+
+	return bounded.CalculateBoundedPacks(ctx, storage.Sizes(req.packs), stock, req.quantity)
+}
+
+// calculator returns the calculator for the named objective,
+// or the default one when no objective is requested.
+func (h *Handler) calculator(name string, params service.Params) (service.Calculator, error) {
+	if name == "" {
+		return h.calc, nil
+	}
+
+	if h.objectives == nil {
+		return nil, errors.New("objectives are not supported")
+	}
+
+	return h.objectives.New(name, params)
+}
+
+// parseParams reads the objective settings from the query parameters.
+func parseParams(r *http.Request) (service.Params, error) {
+	query := r.URL.Query()
+
+	var params service.Params
+	if maxPacks := query.Get("max_packs"); maxPacks != "" {
+		nr, err := strconv.Atoi(maxPacks)
+		if err != nil {
+			return params, errors.New("max_packs must be a numeric value")
+		}
+
+		params.MaxPacks = nr
+	}
+
+	if surplusCost := query.Get("surplus_cost"); surplusCost != "" {
+		nr, err := strconv.ParseInt(surplusCost, 10, 64)
+		if err != nil {
+			return params, errors.New("surplus_cost must be a numeric value")
+		}
+
+		params.SurplusCost = nr
+	}
+
+	return params, nil
+}
+
+// parseMode reads the order mode from the query parameters,
+// it returns nil when no mode is requested.
+func parseMode(r *http.Request) (*service.Tolerance, error) {
+	query := r.URL.Query()
+
+	switch mode := query.Get("mode"); mode {
+	case "":
+		return nil, nil
+	case modeExact:
+		none := 0
+		return &service.Tolerance{MaxSurplus: &none}, nil
+	case modeAllowShort:
+		nr, err := strconv.Atoi(query.Get("max_short"))
+		if err != nil || nr < 0 {
+			return nil, errors.New("max_short must be a number greater than or equal to zero")
+		}
+
+		return &service.Tolerance{MaxShort: nr}, nil
+	case modeMaxSurplus:
+		nr, err := strconv.Atoi(query.Get("max_surplus"))
+		if err != nil || nr < 0 {
+			return nil, errors.New("max_surplus must be a number greater than or equal to zero")
+		}
+
+		return &service.Tolerance{MaxSurplus: &nr}, nil
+	default:
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
 }
@@ -1,31 +1,84 @@
 package order
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reparttask/service"
 	"reparttask/service/bestfit"
+	"reparttask/service/dp"
+	"reparttask/service/hierarchy"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 )
 
 type DbMock struct {
-	data []int
+	data   []storage.Pack
+	levels []storage.Level
 }
 
 func NewDbMock(dt []int) *DbMock {
-	return &DbMock{data: dt}
+	packs := make([]storage.Pack, len(dt))
+	for i, size := range dt {
+		packs[i] = storage.Pack{Size: size}
+	}
+	return &DbMock{data: packs}
+}
+
+func NewDbMockWithPacks(packs []storage.Pack) *DbMock {
+	return &DbMock{data: packs}
+}
+
+func (db *DbMock) AddPacks(ctx context.Context, packs []storage.Pack) error { return nil }
+
+func (db *DbMock) RemovePack(ctx context.Context, size int) error { return nil }
+
+func (db *DbMock) GetPacks(ctx context.Context) ([]storage.Pack, error) { return db.data, nil }
+
+func (db *DbMock) RemovePacks(ctx context.Context) error { return nil }
+
+func (db *DbMock) ReplacePacks(ctx context.Context, packs []storage.Pack, version int) error {
+	return nil
+}
+
+func (db *DbMock) ReservePacks(ctx context.Context, packs map[int]int) error { return nil }
+
+func (db *DbMock) SetLevels(ctx context.Context, levels []storage.Level) error {
+	db.levels = levels
+	return nil
+}
+
+func (db *DbMock) GetLevels(ctx context.Context) ([]storage.Level, error) { return db.levels, nil }
+
+func (db *DbMock) History(ctx context.Context) ([]storage.PackSet, error) { return nil, nil }
+
+func (db *DbMock) PackSet(ctx context.Context, version int) (storage.PackSet, error) {
+	return storage.PackSet{}, storage.Errorf(storage.ErrNotFound, "version %d not found", version)
 }
 
-func (db *DbMock) AddPacks(size []int) error { return nil }
+func (db *DbMock) Rollback(ctx context.Context, version int) error { return nil }
 
-func (db *DbMock) RemovePack(size int) error { return nil }
+func (db *DbMock) SchedulePacks(ctx context.Context, packs []storage.Pack, from time.Time) (storage.Schedule, error) {
+	return storage.Schedule{}, nil
+}
+
+func (db *DbMock) Schedules(ctx context.Context) ([]storage.Schedule, error) { return nil, nil }
 
-func (db *DbMock) GetPacks() []int { return db.data }
+func (db *DbMock) CancelSchedule(ctx context.Context, id int) error { return nil }
 
-func (db *DbMock) RemovePacks() {}
+func (db *DbMock) ApplySchedules(ctx context.Context) (int, error) { return 0, nil }
+
+func (db *DbMock) ActivePackSet(ctx context.Context, at time.Time) (storage.PackSet, error) {
+	return storage.PackSet{Packs: db.data}, nil
+}
 
 func TestHandler_handleGetOrder(t *testing.T) {
 	type testCaseInput struct {
@@ -92,8 +145,8 @@ func TestHandler_handleGetOrder(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := &Handler{
-				db:   NewDbMock(getValues(tt.input.data)),
-				calc: bestfit.NewCalc(),
+				products: storage.NewProducts(NewDbMock(getValues(tt.input.data)), nil),
+				calc:     bestfit.NewCalc(),
 			}
 
 			req := httptest.NewRequest(http.MethodGet, "/orders/{items}", nil)
@@ -139,10 +192,1395 @@ func TestHandler_handleGetOrder(t *testing.T) {
 	}
 }
 
-func getValues(input map[int]int) []int {
-	var result []int
-	for _, v := range input {
-		result = append(result, v)
+func TestHandler_handleGetOrderV2(t *testing.T) {
+	type testCaseInput struct {
+		data     map[int]int
+		quantity string
 	}
-	return result
+	type testCaseOutput struct {
+		status int
+		want   service.Result
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	storageMap := map[int]int{250: 250, 500: 500, 1000: 1000, 2000: 2000, 5000: 5000}
+
+	tests := []testCase{
+		{
+			name: "test for 12001 order size",
+			input: testCaseInput{
+				data:     storageMap,
+				quantity: "12001",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want: service.Result{
+					Packs: []service.PackLine{
+						{Size: 250, Quantity: 1, UnitCost: 1, Cost: 1},
+						{Size: 2000, Quantity: 1, UnitCost: 1, Cost: 1},
+						{Size: 5000, Quantity: 2, UnitCost: 1, Cost: 2},
+					},
+					OrderedItems: 12001,
+					TotalPacks:   4,
+					TotalItems:   12250,
+					SurplusItems: 249,
+					// packs without a cost are priced at one unit each.
+					Cost:     service.CostBreakdown{Packs: 4, Total: 4},
+					Strategy: dp.Strategy,
+				},
+			},
+		},
+		{
+			name: "test for 500 order size, no surplus",
+			input: testCaseInput{
+				data:     storageMap,
+				quantity: "500",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want: service.Result{
+					Packs:        []service.PackLine{{Size: 500, Quantity: 1, UnitCost: 1, Cost: 1}},
+					OrderedItems: 500,
+					TotalPacks:   1,
+					TotalItems:   500,
+					SurplusItems: 0,
+					Cost:         service.CostBreakdown{Packs: 1, Total: 1},
+					Strategy:     dp.Strategy,
+				},
+			},
+		},
+		{
+			name: "test for 2000000001 order size",
+			input: testCaseInput{
+				data:     storageMap,
+				quantity: "2000000001",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want: service.Result{
+					Packs:        []service.PackLine{{Size: 250, Quantity: 1, UnitCost: 1, Cost: 1}, {Size: 5000, Quantity: 400_000, UnitCost: 1, Cost: 400_000}},
+					OrderedItems: 2_000_000_001,
+					TotalPacks:   400_001,
+					TotalItems:   2_000_000_250,
+					SurplusItems: 249,
+					Cost:         service.CostBreakdown{Packs: 400_001, Total: 400_001},
+					Strategy:     dp.Strategy,
+				},
+			},
+		},
+		{
+			name: "test error for no packs stored, error returned",
+			input: testCaseInput{
+				data:     map[int]int{},
+				quantity: "500",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("you must first add some packaging sizes"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{
+				products: storage.NewProducts(NewDbMock(getValues(tt.input.data)), nil),
+				calc:     dp.NewCalc(),
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/order/{items}", nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetOrderV2(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			var data service.Result
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderObjective(t *testing.T) {
+	type testCaseInput struct {
+		quantity string
+		query    string
+	}
+	type testCaseOutput struct {
+		status int
+		want   map[string]int
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	tests := []testCase{
+		{
+			name: "test min-packs objective for 12001 order size",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "objective=min-packs",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"5000": 3},
+			},
+		},
+		{
+			name: "test max-packs objective for 12001 order size",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "objective=max-packs&max_packs=3",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"5000": 3},
+			},
+		},
+		{
+			name: "test max-packs objective without solution, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "objective=max-packs&max_packs=2",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrNoSolution,
+			},
+		},
+		{
+			name: "test max-packs objective with invalid limit, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "objective=max-packs&max_packs=test",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("max_packs must be a numeric value"),
+			},
+		},
+		{
+			name: "test unknown objective, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "objective=test",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New(`unknown objective "test"`),
+			},
+		},
+	}
+
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(NewDbMock([]int{250, 500, 1000, 2000, 5000}), nil), dp.NewCalc(), objectives, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/order/{items}?"+tt.input.query, nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetOrder(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			data := map[string]int{}
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderMode(t *testing.T) {
+	type testCaseInput struct {
+		quantity string
+		query    string
+		calc     service.Calculator
+	}
+	type testCaseOutput struct {
+		status int
+		want   map[string]int
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	tests := []testCase{
+		{
+			name: "test exact mode for 12000 order size",
+			input: testCaseInput{
+				quantity: "12000",
+				query:    "mode=exact",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"5000": 2, "2000": 1},
+			},
+		},
+		{
+			name: "test exact mode without exact packing, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=exact",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrNoSolution,
+			},
+		},
+		{
+			name: "test exact mode with min-packs objective",
+			input: testCaseInput{
+				quantity: "4750",
+				query:    "mode=exact&objective=min-packs",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"2000": 2, "500": 1, "250": 1},
+			},
+		},
+		{
+			name: "test allow-short mode for 12001 order size",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=allow-short&max_short=1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"5000": 2, "2000": 1},
+			},
+		},
+		{
+			name: "test allow-short mode with invalid shortfall, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=allow-short&max_short=-1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("max_short must be a number greater than or equal to zero"),
+			},
+		},
+		{
+			name: "test allow-short mode short of the whole order, error returned",
+			input: testCaseInput{
+				quantity: "100",
+				query:    "mode=allow-short&max_short=100",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("max_short must be less than the number of items"),
+			},
+		},
+		{
+			name: "test max-surplus mode for 12001 order size",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=max-surplus&max_surplus=249",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"5000": 2, "2000": 1, "250": 1},
+			},
+		},
+		{
+			name: "test max-surplus mode without solution, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=max-surplus&max_surplus=100",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrNoSolution,
+			},
+		},
+		{
+			name: "test max-surplus mode without threshold, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=max-surplus",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("max_surplus must be a number greater than or equal to zero"),
+			},
+		},
+		{
+			name: "test unknown mode, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=test",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New(`unknown mode "test"`),
+			},
+		},
+		{
+			name: "test mode with a calculator without modes, error returned",
+			input: testCaseInput{
+				quantity: "12001",
+				query:    "mode=exact",
+				calc:     bestfit.NewCalc(),
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrModeNotSupported,
+			},
+		},
+	}
+
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calc := tt.input.calc
+			if calc == nil {
+				calc = dp.NewCalc()
+			}
+			h := NewHandler(storage.NewProducts(NewDbMock([]int{250, 500, 1000, 2000, 5000}), nil), calc, objectives, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/order/{items}?"+tt.input.query, nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetOrder(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			data := map[string]int{}
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderShortV2(t *testing.T) {
+	h := NewHandler(storage.NewProducts(NewDbMock([]int{250, 500}), nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/order/{items}?mode=allow-short&max_short=10", nil)
+	req.SetPathValue("items", "255")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res service.Result
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, 250, res.TotalItems)
+	assert.Equal(t, 5, res.ShortItems)
+	assert.Equal(t, 0, res.SurplusItems)
+}
+
+func TestHandler_handleGetOrderPackInfoV2(t *testing.T) {
+	packs := []storage.Pack{
+		{Size: 250, Name: "Small box", Barcode: "SB-250", Dimensions: &storage.Dimensions{Length: 300, Width: 200, Height: 100}, TareWeight: 120},
+		{Size: 500, TareWeight: 200},
+	}
+	h := NewHandler(storage.NewProducts(NewDbMockWithPacks(packs), nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/order/{items}", nil)
+	req.SetPathValue("items", "751")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var res service.Result
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, []service.PackLine{
+		{Size: 500, Quantity: 2, UnitCost: 1, Cost: 2, PackInfo: service.PackInfo{TareWeight: 200}},
+	}, res.Packs)
+	assert.Equal(t, 400, res.TotalWeight)
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/order/{items}", nil)
+	req.SetPathValue("items", "250")
+
+	w = httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	res = service.Result{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, []service.PackLine{{
+		Size:     250,
+		Quantity: 1,
+		UnitCost: 1,
+		Cost:     1,
+		PackInfo: service.PackInfo{
+			Name:       "Small box",
+			Barcode:    "SB-250",
+			Dimensions: &storage.Dimensions{Length: 300, Width: 200, Height: 100},
+			TareWeight: 120,
+		},
+	}}, res.Packs)
+	assert.Equal(t, 120, res.TotalWeight)
+}
+
+func TestHandler_handleGetOrderNested(t *testing.T) {
+	db := NewDbMock([]int{250, 500, 1000, 2000, 5000})
+	db.SetLevels(context.Background(), []storage.Level{
+		{Name: "carton", Capacities: []int{3, 6}},
+		{Name: "pallet", Capacities: []int{10}},
+	})
+	h := NewHandler(storage.NewProducts(db, nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/order/{items}?nested=true", nil)
+	req.SetPathValue("items", "12001")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrder(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var plan hierarchy.Plan
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+
+	// 4 packs go into a carton of 6, which goes onto a pallet of 10.
+	assert.Equal(t, "pallet", plan.Level)
+	assert.Equal(t, map[int]int{10: 1}, plan.Packing.Map())
+	assert.Equal(t, "carton", plan.Contents.Level)
+	assert.Equal(t, map[int]int{6: 1}, plan.Contents.Packing.Map())
+	assert.Equal(t, 2, plan.Contents.Packing.SurplusItems)
+	assert.Equal(t, "pack", plan.Contents.Contents.Level)
+	assert.Equal(t, map[int]int{5000: 2, 2000: 1, 250: 1}, plan.Contents.Contents.Packing.Map())
+	assert.Nil(t, plan.Contents.Contents.Contents)
+}
+
+func TestHandler_handleGetOrderNestedWithoutLevels(t *testing.T) {
+	h := NewHandler(storage.NewProducts(NewDbMock([]int{250, 500}), nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/order/{items}?nested=true", nil)
+	req.SetPathValue("items", "251")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrder(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var plan hierarchy.Plan
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &plan))
+	assert.Equal(t, "pack", plan.Level)
+	assert.Equal(t, map[int]int{500: 1}, plan.Packing.Map())
+	assert.Nil(t, plan.Contents)
+}
+
+func TestHandler_handleGetOrderCost(t *testing.T) {
+	type testCaseInput struct {
+		quantity string
+		query    string
+	}
+	type testCaseOutput struct {
+		status int
+		packs  []service.PackLine
+		cost   service.CostBreakdown
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	packs := []storage.Pack{{Size: 250, Cost: cost(10)}, {Size: 500, Cost: cost(12)}, {Size: 1000, Cost: cost(30)}}
+
+	tests := []testCase{
+		{
+			name: "test default calculator reports cost",
+			input: testCaseInput{
+				quantity: "1000",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []service.PackLine{{Size: 1000, Quantity: 1, UnitCost: 30, Cost: 30}},
+				cost:   service.CostBreakdown{Packs: 30, Total: 30},
+			},
+		},
+		{
+			name: "test min-cost objective",
+			input: testCaseInput{
+				quantity: "1000",
+				query:    "objective=min-cost",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []service.PackLine{{Size: 500, Quantity: 2, UnitCost: 12, Cost: 24}},
+				cost:   service.CostBreakdown{Packs: 24, Total: 24},
+			},
+		},
+		{
+			name: "test min-cost objective with priced surplus",
+			input: testCaseInput{
+				quantity: "501",
+				query:    "objective=min-cost&surplus_cost=1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []service.PackLine{{Size: 250, Quantity: 1, UnitCost: 10, Cost: 10}, {Size: 500, Quantity: 1, UnitCost: 12, Cost: 12}},
+				cost:   service.CostBreakdown{Packs: 22, Surplus: 249, Total: 271},
+			},
+		},
+		{
+			name: "test invalid surplus cost, error returned",
+			input: testCaseInput{
+				quantity: "501",
+				query:    "objective=min-cost&surplus_cost=test",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("surplus_cost must be a numeric value"),
+			},
+		},
+	}
+
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(NewDbMockWithPacks(packs), nil), dp.NewCalc(), objectives, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/v2/order/{items}?"+tt.input.query, nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetOrderV2(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			var data service.Result
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.packs, data.Packs)
+			assert.Equal(t, tt.expected.cost, data.Cost)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderCostMissing(t *testing.T) {
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
+
+	// the pack without a cost counts as one unit, so four of them cost more than the priced pack.
+	packs := []storage.Pack{{Size: 250}, {Size: 1000, Cost: cost(2)}}
+	h := NewHandler(storage.NewProducts(NewDbMockWithPacks(packs), nil), dp.NewCalc(), objectives, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/order/{items}?objective=min-cost", nil)
+	req.SetPathValue("items", "1000")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var data service.Result
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, []service.PackLine{{Size: 1000, Quantity: 1, UnitCost: 2, Cost: 2}}, data.Packs)
+
+	// the reported cost is the one minimised, a free pack wins.
+	packs = []storage.Pack{{Size: 250}, {Size: 1000, Cost: cost(0)}}
+	h = NewHandler(storage.NewProducts(NewDbMockWithPacks(packs), nil), dp.NewCalc(), objectives, Options{})
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/order/{items}?objective=min-cost", nil)
+	req.SetPathValue("items", "250")
+
+	w = httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	data = service.Result{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, []service.PackLine{{Size: 1000, Quantity: 1}}, data.Packs)
+	assert.Equal(t, service.CostBreakdown{}, data.Cost)
+
+	req = httptest.NewRequest(http.MethodGet, "/v2/order/{items}", nil)
+	req.SetPathValue("items", "250")
+
+	w = httptest.NewRecorder()
+	h.handleGetOrderV2(w, req)
+
+	data = service.Result{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+	assert.Equal(t, []service.PackLine{{Size: 250, Quantity: 1, UnitCost: 1, Cost: 1}}, data.Packs)
+	assert.Equal(t, service.CostBreakdown{Packs: 1, Total: 1}, data.Cost)
+}
+
+func TestHandler_handleGetOrderStock(t *testing.T) {
+	type testCaseInput struct {
+		packs    []storage.Pack
+		calc     service.Calculator
+		quantity string
+	}
+	type testCaseOutput struct {
+		status int
+		want   map[string]int
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	one, two := 1, 2
+
+	tests := []testCase{
+		{
+			name: "test limited stock is respected",
+			input: testCaseInput{
+				packs:    []storage.Pack{{Size: 250}, {Size: 500}, {Size: 1000, Stock: &one}},
+				calc:     dp.NewCalc(),
+				quantity: "2000",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"1000": 1, "500": 2},
+			},
+		},
+		{
+			name: "test not enough stock, error returned",
+			input: testCaseInput{
+				packs:    []storage.Pack{{Size: 250, Stock: &two}, {Size: 500, Stock: &one}},
+				calc:     dp.NewCalc(),
+				quantity: "1001",
+			},
+			expected: testCaseOutput{
+				status: http.StatusConflict,
+				err:    errors.New(storage.ErrInsufficientStock.Error()),
+			},
+		},
+		{
+			name: "test calculator without stock support, error returned",
+			input: testCaseInput{
+				packs:    []storage.Pack{{Size: 250, Stock: &two}},
+				calc:     bestfit.NewCalc(),
+				quantity: "250",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrStockNotSupported,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(NewDbMockWithPacks(tt.input.packs), nil), tt.input.calc, nil, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/order/{items}", nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetOrder(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			data := map[string]int{}
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderVersion(t *testing.T) {
+	type testCaseInput struct {
+		version string
+	}
+	type testCaseOutput struct {
+		status int
+		want   map[string]int
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	tests := []testCase{
+		{
+			name:  "test order priced against the current packs",
+			input: testCaseInput{},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"300": 2},
+			},
+		},
+		{
+			name:  "test order priced against an older version",
+			input: testCaseInput{version: "1"},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"250": 1, "500": 1},
+			},
+		},
+		{
+			name:  "test order priced against an unknown version, error returned",
+			input: testCaseInput{version: "3"},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				err:    errors.New("version 3 not found"),
+			},
+		},
+		{
+			name:  "test order priced against an invalid version, error returned",
+			input: testCaseInput{version: "latest"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("version must be a number greater than zero"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 250}, {Size: 500}, {Size: 1000}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.ReplacePacks(context.Background(), []storage.Pack{{Size: 300}}, storage.AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewHandler(storage.NewProducts(db, nil), bestfit.NewCalc(), nil, Options{})
+
+			target := "/order/{items}"
+			if tt.input.version != "" {
+				target += "?version=" + tt.input.version
+			}
+
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			req.SetPathValue("items", "600")
+
+			w := httptest.NewRecorder()
+			h.handleGetOrder(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+				return
+			}
+
+			data := map[string]int{}
+			if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderAt(t *testing.T) {
+	type testCaseInput struct {
+		query string
+	}
+	type testCaseOutput struct {
+		status int
+		want   map[string]int
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []testCase{
+		{
+			name:  "test order priced against the packs active now",
+			input: testCaseInput{},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"250": 1, "500": 1},
+			},
+		},
+		{
+			name:  "test order priced against a scheduled change",
+			input: testCaseInput{query: "?at=2026-10-01T02:00:00Z"},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   map[string]int{"300": 2},
+			},
+		},
+		{
+			name:  "test order priced before the first packs, error returned",
+			input: testCaseInput{query: "?at=2026-09-30T00:00:00Z"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("you must first add some packaging sizes"),
+			},
+		},
+		{
+			name:  "test order priced at an invalid time, error returned",
+			input: testCaseInput{query: "?at=tomorrow"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("at must be an RFC 3339 time, eg. 2026-01-02T15:04:05Z"),
+			},
+		},
+		{
+			name:  "test order priced at a time and a version, error returned",
+			input: testCaseInput{query: "?at=2026-10-01T02:00:00Z&version=1"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("version and at can't be used together"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			db := memory.NewMemDB()
+			db.SetClock(func() time.Time { return now })
+
+			if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 250}, {Size: 500}, {Size: 1000}}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.SchedulePacks(context.Background(), []storage.Pack{{Size: 300}}, start.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			now = start.Add(30 * time.Minute)
+
+			h := NewHandler(storage.NewProducts(db, nil), bestfit.NewCalc(), nil, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/order/{items}"+tt.input.query, nil)
+			req.SetPathValue("items", "600")
+
+			w := httptest.NewRecorder()
+			h.handleGetOrder(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+				return
+			}
+
+			data := map[string]int{}
+			if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.want, data)
+		})
+	}
+}
+
+func TestHandler_handleConfirmOrderSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+
+	db := memory.NewMemDB()
+	db.SetClock(func() time.Time { return now })
+
+	if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 250}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SchedulePacks(context.Background(), []storage.Pack{{Size: 300}}, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the schedule is due but the scheduler didn't run yet.
+	now = start.Add(2 * time.Hour)
+
+	h := NewHandler(storage.NewProducts(db, nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/order/{items}/confirm", nil)
+	req.SetPathValue("items", "300")
+
+	w := httptest.NewRecorder()
+	h.handleConfirmOrder(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	packs, err := db.GetPacks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []int{300}, storage.Sizes(packs))
+}
+
+func TestHandler_handleConfirmOrderVersion(t *testing.T) {
+	db := memory.NewMemDB()
+	if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 250}}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(storage.NewProducts(db, nil), dp.NewCalc(), nil, Options{})
+
+	req := httptest.NewRequest(http.MethodPost, "/order/{items}/confirm?version=1", nil)
+	req.SetPathValue("items", "250")
+
+	w := httptest.NewRecorder()
+	h.handleConfirmOrder(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_handleGetAlternatives(t *testing.T) {
+	type testCaseInput struct {
+		packs    []storage.Pack
+		calc     service.Calculator
+		quantity string
+		query    string
+	}
+	type testCaseOutput struct {
+		status      int
+		want        []map[int]int
+		explanation string
+		err         error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	packs := []storage.Pack{{Size: 250}, {Size: 500}, {Size: 1000}, {Size: 2000}, {Size: 5000}}
+	one := 1
+
+	tests := []testCase{
+		{
+			name: "test default number of alternatives",
+			input: testCaseInput{
+				packs:    packs,
+				calc:     dp.NewCalc(),
+				quantity: "751",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   []map[int]int{{1000: 1}, {500: 2}, {500: 1, 250: 2}},
+			},
+		},
+		{
+			name: "test explanation in debug mode",
+			input: testCaseInput{
+				packs:    packs,
+				calc:     dp.NewCalc(),
+				quantity: "751",
+				query:    "k=2&debug=true",
+			},
+			expected: testCaseOutput{
+				status:      http.StatusOK,
+				want:        []map[int]int{{1000: 1}, {500: 2}},
+				explanation: "same surplus, fewer packs (1 vs 2)",
+			},
+		},
+		{
+			name: "test alternatives for the requested objective",
+			input: testCaseInput{
+				packs:    packs,
+				calc:     dp.NewCalc(),
+				quantity: "501",
+				query:    "k=2&objective=min-packs",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				want:   []map[int]int{{1000: 1}, {2000: 1}},
+			},
+		},
+		{
+			name: "test invalid k, error returned",
+			input: testCaseInput{
+				packs:    packs,
+				calc:     dp.NewCalc(),
+				quantity: "751",
+				query:    "k=0",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("k must be a number between 1 and 20"),
+			},
+		},
+		{
+			name: "test calculator without alternatives, error returned",
+			input: testCaseInput{
+				packs:    packs,
+				calc:     bestfit.NewCalc(),
+				quantity: "751",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrAlternativesNotSupported,
+			},
+		},
+		{
+			name: "test limited stock, error returned",
+			input: testCaseInput{
+				packs:    []storage.Pack{{Size: 250, Stock: &one}},
+				calc:     dp.NewCalc(),
+				quantity: "1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusUnprocessableEntity,
+				err:    service.ErrStockNotSupported,
+			},
+		},
+	}
+
+	objectives := service.NewRegistry()
+	dp.Register(objectives)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(NewDbMockWithPacks(tt.input.packs), nil), tt.input.calc, objectives, Options{})
+
+			req := httptest.NewRequest(http.MethodGet, "/order/{items}/alternatives?"+tt.input.query, nil)
+			req.SetPathValue("items", tt.input.quantity)
+
+			w := httptest.NewRecorder()
+			h.handleGetAlternatives(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			var data AlternativesResponse
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make([]map[int]int, len(data.Alternatives))
+			for i, res := range data.Alternatives {
+				got[i] = res.Map()
+			}
+
+			assert.Equal(t, tt.expected.want, got)
+			assert.Equal(t, tt.expected.explanation, data.Explanation)
+		})
+	}
+}
+
+func TestHandler_handleGetOrderTimeout(t *testing.T) {
+	// small co-prime packs make the recursive search explode for this order size.
+	h := NewHandler(storage.NewProducts(NewDbMock([]int{23, 31, 53}), nil), bestfit.NewCalc(), nil, Options{Timeout: 50 * time.Millisecond})
+
+	req := httptest.NewRequest(http.MethodGet, "/order/{items}", nil)
+	req.SetPathValue("items", "50000")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrder(w, req)
+
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.JSONEq(t, `{"error":"request timed out"}`, w.Body.String())
+}
+
+func TestHandler_handleGetOrderCancelled(t *testing.T) {
+	h := NewHandler(storage.NewProducts(NewDbMock([]int{23, 31, 53}), nil), dp.NewCalc(), nil, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/order/{items}", nil).WithContext(ctx)
+	req.SetPathValue("items", "10000000")
+
+	w := httptest.NewRecorder()
+	h.handleGetOrder(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":"request cancelled"}`, w.Body.String())
+}
+
+func TestHandler_handleConfirmOrderConcurrent(t *testing.T) {
+	const confirmations = 50
+
+	// only one 1000 pack is left, so exactly one confirmation can succeed.
+	one := 1
+	db := memory.NewMemDB()
+	err := db.AddPacks(context.Background(), []storage.Pack{{Size: 1000, Stock: &one}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(storage.NewProducts(db, nil), dp.NewCalc(), nil, Options{})
+
+	var wg sync.WaitGroup
+	statuses := make(chan int, confirmations)
+	for i := 0; i < confirmations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			req := httptest.NewRequest(http.MethodPost, "/order/{items}/confirm", nil)
+			req.SetPathValue("items", "1000")
+
+			w := httptest.NewRecorder()
+			h.handleConfirmOrder(w, req)
+			statuses <- w.Code
+		}()
+	}
+
+	wg.Wait()
+	close(statuses)
+
+	created := 0
+	for status := range statuses {
+		switch status {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+		default:
+			t.Errorf("unexpected status %d", status)
+		}
+	}
+
+	assert.Equal(t, 1, created)
+	packs, err := db.GetPacks(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, *packs[0].Stock)
+}
+
+func TestHandler_handleGetOrderConcurrent(t *testing.T) {
+	const requests = 2000
+
+	calculators := map[string]func() service.Calculator{
+		"bestfit": func() service.Calculator { return bestfit.NewCalc() },
+		"dp":      func() service.Calculator { return dp.NewCalc() },
+	}
+
+	for name, newCalc := range calculators {
+		t.Run(name, func(t *testing.T) {
+			packs := []storage.Pack{{Size: 5000}, {Size: 250}, {Size: 2000}, {Size: 500}, {Size: 1000}}
+			stored := []int{5000, 250, 2000, 500, 1000}
+
+			// compute the expected results sequentially with a dedicated instance.
+			expected := make([]map[int]int, requests)
+			for i := range expected {
+				res, err := newCalc().CalculatePacks(context.Background(), stored, quantityFor(i))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				expected[i] = res.Map()
+			}
+
+			// every request shares the same handler, calculator and stored slice.
+			h := &Handler{
+				products: storage.NewProducts(NewDbMockWithPacks(packs), nil),
+				calc:     newCalc(),
+			}
+
+			var wg sync.WaitGroup
+			errs := make(chan string, requests)
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+
+					req := httptest.NewRequest(http.MethodGet, "/orders/{items}", nil)
+					req.SetPathValue("items", strconv.Itoa(quantityFor(i)))
+
+					w := httptest.NewRecorder()
+					h.handleGetOrder(w, req)
+
+					data := map[string]int{}
+					if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+						errs <- err.Error()
+						return
+					}
+
+					if len(data) != len(expected[i]) {
+						errs <- "unexpected result for " + strconv.Itoa(quantityFor(i))
+						return
+					}
+
+					for k, v := range expected[i] {
+						if data[strconv.Itoa(k)] != v {
+							errs <- "unexpected result for " + strconv.Itoa(quantityFor(i))
+							return
+						}
+					}
+				}(i)
+			}
+
+			wg.Wait()
+			close(errs)
+
+			for e := range errs {
+				t.Error(e)
+			}
+
+			assert.Equal(t, stored, storage.Sizes(packs), "stored packs must not be reordered")
+		})
+	}
+}
+
+// quantityFor spreads the stress test requests over a range of order sizes.
+func quantityFor(i int) int {
+	return (i*251)%3000 + 1
+}
+
+func getValues(input map[int]int) []int {
+	var result []int
+	for _, v := range input {
+		result = append(result, v)
+	}
+	return result
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
 }
@@ -1,83 +1,560 @@
 package pack
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"reparttask/storage"
 	"reparttask/utils"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// SizePayload accepts bare sizes, which are stored without a cost or metadata,
+// and/or packs with their cost, stock and metadata.
 type SizePayload struct {
-	Sizes []int `json:"sizes"`
+	Sizes []int          `json:"sizes,omitempty"`
+	Packs []storage.Pack `json:"packs,omitempty"`
+}
+
+// PacksResponse holds the stored packs sorted by size, together with the latest version
+// of their history, which is also sent in the ETag header.
+type PacksResponse struct {
+	Status  string         `json:"status"`
+	Version int            `json:"version"`
+	Packs   []storage.Pack `json:"packs"`
+}
+
+// SchedulePayload holds the packs replacing every pack from EffectiveFrom, in the SizePayload shape.
+type SchedulePayload struct {
+	SizePayload
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// SchedulesResponse holds the scheduled changes not applied yet, by effective time.
+type SchedulesResponse struct {
+	Status    string             `json:"status"`
+	Schedules []storage.Schedule `json:"schedules"`
+}
+
+// HistoryResponse holds every version of the packs, oldest first.
+type HistoryResponse struct {
+	Status   string            `json:"status"`
+	Versions []storage.PackSet `json:"versions"`
+}
+
+// toPacks merges the bare sizes and the packs of the payload.
+func (p SizePayload) toPacks() []storage.Pack {
+	packs := make([]storage.Pack, 0, len(p.Sizes)+len(p.Packs))
+	for _, size := range p.Sizes {
+		packs = append(packs, storage.Pack{Size: size})
+	}
+
+	return append(packs, p.Packs...)
+}
+
+// LevelsPayload holds the packaging hierarchy above the packs, ordered from the packs outwards.
+type LevelsPayload struct {
+	Levels []storage.Level `json:"levels"`
 }
 
 type Handler struct {
-	db storage.Storage
+	products storage.Catalog
 }
 
-func NewHandler(db storage.Storage) *Handler {
-	return &Handler{db: db}
+func NewHandler(products storage.Catalog) *Handler {
+	return &Handler{products: products}
 }
 
 func (h *Handler) RegisterRoutes(router *http.ServeMux) {
+	router.HandleFunc("GET /packs", h.handleGetPacks)
+	router.HandleFunc("GET /pack/{size}", h.handleGetPack)
 	router.HandleFunc("POST /pack", h.handleAddPacks)
+	router.HandleFunc("PUT /packs", h.handleReplacePacks)
 	router.HandleFunc("DELETE /pack/{size}", h.handleRemovePack)
 	router.HandleFunc("DELETE /packs", h.handleRemovePacks)
+	router.HandleFunc("GET /packs/history", h.handleGetHistory)
+	router.HandleFunc("POST /packs/rollback/{version}", h.handleRollback)
+	router.HandleFunc("GET /packs/schedules", h.handleGetSchedules)
+	router.HandleFunc("POST /packs/schedules", h.handleSchedulePacks)
+	router.HandleFunc("DELETE /packs/schedules/{id}", h.handleCancelSchedule)
+	router.HandleFunc("GET /levels", h.handleGetLevels)
+	router.HandleFunc("PUT /levels", h.handleSetLevels)
+
+	// the same endpoints scoped to a product, the ones above use the default product.
+	router.HandleFunc("GET /products/{sku}/packs", h.handleGetPacks)
+	router.HandleFunc("GET /products/{sku}/packs/{size}", h.handleGetPack)
+	router.HandleFunc("POST /products/{sku}/packs", h.handleAddPacks)
+	router.HandleFunc("PUT /products/{sku}/packs", h.handleReplacePacks)
+	router.HandleFunc("DELETE /products/{sku}/packs/{size}", h.handleRemovePack)
+	router.HandleFunc("DELETE /products/{sku}/packs", h.handleRemovePacks)
+	router.HandleFunc("GET /products/{sku}/packs/history", h.handleGetHistory)
+	router.HandleFunc("POST /products/{sku}/packs/rollback/{version}", h.handleRollback)
+	router.HandleFunc("GET /products/{sku}/packs/schedules", h.handleGetSchedules)
+	router.HandleFunc("POST /products/{sku}/packs/schedules", h.handleSchedulePacks)
+	router.HandleFunc("DELETE /products/{sku}/packs/schedules/{id}", h.handleCancelSchedule)
+	router.HandleFunc("GET /products/{sku}/levels", h.handleGetLevels)
+	router.HandleFunc("PUT /products/{sku}/levels", h.handleSetLevels)
 }
 
 func (h *Handler) handleAddPacks(w http.ResponseWriter, r *http.Request) {
+	packs, ok := readPacks(w, r)
+	if !ok {
+		return
+	}
+
+	db, ok := utils.Product(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	ctx := utils.Context(r)
+	err := db.AddPacks(ctx, packs)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	version, stored, err := current(ctx, db)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	writePacks(w, http.StatusCreated, version, stored)
+}
+
+// handleReplacePacks replaces every pack of a product at once, so orders never see a partial set.
+// When If-Match is set it must list the current version of the packs, otherwise 409 is returned.
+func (h *Handler) handleReplacePacks(w http.ResponseWriter, r *http.Request) {
+	packs, ok := readPacks(w, r)
+	if !ok {
+		return
+	}
+
+	db, ok := utils.Product(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	ctx := utils.Context(r)
+
+	// without If-Match, or with "*", the packs are replaced whatever their version.
+	version := storage.AnyVersion
+	if header := r.Header.Get("If-Match"); header != "" {
+		versions, wildcard := parseETags(header, false)
+		if !wildcard {
+			latest, err := latest(ctx, db)
+			if err != nil {
+				utils.WriteStorageError(w, err)
+				return
+			}
+
+			if !slices.Contains(versions, latest) {
+				utils.WriteStorageError(w, storage.VersionConflict(latest))
+				return
+			}
+
+			// the storage checks it again, the packs may change in the meantime.
+			version = latest
+		}
+	}
+
+	err := db.ReplacePacks(ctx, packs, version)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	version, stored, err := current(ctx, db)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	writePacks(w, http.StatusOK, version, stored)
+}
+
+func (h *Handler) handleRemovePack(w http.ResponseWriter, r *http.Request) {
+	nr, ok := parseSize(w, r)
+	if !ok {
+		return
+	}
+
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	err := db.RemovePack(utils.Context(r), nr)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+func (h *Handler) handleRemovePacks(w http.ResponseWriter, r *http.Request) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	err := db.RemovePacks(utils.Context(r))
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// handleGetHistory returns every version of the packs of a product, oldest first.
+func (h *Handler) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	versions, err := db.History(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, HistoryResponse{Status: "success", Versions: versions})
+}
+
+// handleRollback restores the packs of a version as a new version, the stock of sizes still stored is kept.
+func (h *Handler) handleRollback(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version <= 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "the version must be a positive number"})
+		return
+	}
+
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	ctx := utils.Context(r)
+	err = db.Rollback(ctx, version)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	version, stored, err := current(ctx, db)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	writePacks(w, http.StatusOK, version, stored)
+}
+
+// handleGetSchedules returns the scheduled changes of a product not applied yet.
+func (h *Handler) handleGetSchedules(w http.ResponseWriter, r *http.Request) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	schedules, err := db.Schedules(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, SchedulesResponse{Status: "success", Schedules: schedules})
+}
+
+// handleSchedulePacks schedules the packs to replace every pack of a product from the effective time.
+func (h *Handler) handleSchedulePacks(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 
-	var pk SizePayload
-	err := json.NewDecoder(r.Body).Decode(&pk)
+	var payload SchedulePayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	if len(pk.Sizes) == 0 {
-		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "pack size must be positive"})
+	if payload.EffectiveFrom.IsZero() {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "effective_from must be set"})
+		return
+	}
+
+	packs, ok := checkPacks(w, payload.toPacks())
+	if !ok {
+		return
+	}
+
+	db, ok := utils.Product(w, r, h.products)
+	if !ok {
 		return
 	}
 
-	err = h.db.AddPacks(pk.Sizes)
+	schedule, err := db.SchedulePacks(utils.Context(r), packs, payload.EffectiveFrom)
 	if err != nil {
-		utils.WriteOutput(w, http.StatusInternalServerError, map[string]string{"error": "an error has occurred"})
+		utils.WriteStorageError(w, err)
 		return
 	}
 
-	utils.WriteOutput(w, http.StatusCreated, map[string]string{"status": "success"})
+	utils.WriteOutput(w, http.StatusCreated, schedule)
 }
 
-func (h *Handler) handleRemovePack(w http.ResponseWriter, r *http.Request) {
+// handleCancelSchedule drops a scheduled change not applied yet.
+func (h *Handler) handleCancelSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id <= 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "the schedule id must be a positive number"})
+		return
+	}
+
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	err = db.CancelSchedule(utils.Context(r), id)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, map[string]string{"status": "success"})
+}
+
+// handleGetLevels returns the packaging hierarchy above the packs.
+func (h *Handler) handleGetLevels(w http.ResponseWriter, r *http.Request) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	levels, err := db.GetLevels(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, LevelsPayload{Levels: levels})
+}
+
+// handleGetPacks returns the packs of a product sorted by size, with their version.
+// A request whose If-None-Match lists the current version gets a 304 without the packs.
+func (h *Handler) handleGetPacks(w http.ResponseWriter, r *http.Request) {
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	version, packs, err := current(r.Context(), db)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		versions, wildcard := parseETags(header, true)
+		if wildcard || slices.Contains(versions, version) {
+			w.Header().Set("ETag", etag(version))
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	writePacks(w, http.StatusOK, version, packs)
+}
+
+// handleGetPack returns a single pack of a product.
+func (h *Handler) handleGetPack(w http.ResponseWriter, r *http.Request) {
+	size, ok := parseSize(w, r)
+	if !ok {
+		return
+	}
+
+	db, ok := utils.LookupProduct(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	packs, err := db.GetPacks(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	// packs are sorted by size.
+	i := sort.Search(len(packs), func(i int) bool { return packs[i].Size >= size })
+	if i == len(packs) || packs[i].Size != size {
+		utils.WriteStorageError(w, storage.Errorf(storage.ErrNotFound, "size %d not found", size))
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, packs[i])
+}
+
+// handleSetLevels replaces the packaging hierarchy above the packs.
+func (h *Handler) handleSetLevels(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var payload LevelsPayload
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	err = storage.ValidateLevels(payload.Levels)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	db, ok := utils.Product(w, r, h.products)
+	if !ok {
+		return
+	}
+
+	err = db.SetLevels(r.Context(), payload.Levels)
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	levels, err := db.GetLevels(r.Context())
+	if err != nil {
+		utils.WriteStorageError(w, err)
+		return
+	}
+
+	utils.WriteOutput(w, http.StatusOK, LevelsPayload{Levels: levels})
+}
+
+// readPacks decodes and validates the packs of the payload, on failure the error response is already written.
+func readPacks(w http.ResponseWriter, r *http.Request) ([]storage.Pack, bool) {
+	defer r.Body.Close()
+
+	var pk SizePayload
+	err := json.NewDecoder(r.Body).Decode(&pk)
+	if err != nil {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+
+	return checkPacks(w, pk.toPacks())
+}
+
+// checkPacks validates the packs of a payload, on failure the error response is already written.
+func checkPacks(w http.ResponseWriter, packs []storage.Pack) ([]storage.Pack, bool) {
+	if len(packs) == 0 {
+		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "pack size must be positive"})
+		return nil, false
+	}
+
+	for _, pack := range packs {
+		if err := pack.Validate(); err != nil {
+			utils.WriteStorageError(w, err)
+			return nil, false
+		}
+	}
+
+	return packs, true
+}
+
+// parseSize reads the pack size in the path, on failure the error response is already written.
+func parseSize(w http.ResponseWriter, r *http.Request) (int, bool) {
 	size := r.PathValue("size")
 	if size == "" {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must provide a size value"})
-		return
+		return 0, false
 	}
 
 	nr, err := strconv.Atoi(size)
 	if err != nil {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "please provide a numeric value"})
-		return
+		return 0, false
 	}
 
 	if nr <= 0 {
 		utils.WriteOutput(w, http.StatusBadRequest, map[string]string{"error": "you must provide a positive value"})
-		return
+		return 0, false
 	}
 
-	err = h.db.RemovePack(nr)
+	return nr, true
+}
+
+// latest returns the latest version of the packs, 0 before the first one.
+func latest(ctx context.Context, db storage.Storage) (int, error) {
+	versions, err := db.History(ctx)
 	if err != nil {
-		utils.WriteOutput(w, http.StatusInternalServerError, map[string]string{"error": "an error has occurred"})
-		return
+		return 0, err
 	}
 
-	utils.WriteOutput(w, http.StatusOK, map[string]string{"status": "success"})
+	return len(versions), nil
 }
 
-func (h *Handler) handleRemovePacks(w http.ResponseWriter, r *http.Request) {
-	h.db.RemovePacks()
-	utils.WriteOutput(w, http.StatusOK, map[string]string{"status": "success"})
+// current returns the latest version of the packs and the stored packs. The version is read first,
+// so a change in between labels the packs with an older version, which conflicts when replacing them.
+// Stock reservations don't record a version, so the stock may differ for the same version.
+func current(ctx context.Context, db storage.Storage) (int, []storage.Pack, error) {
+	version, err := latest(ctx, db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	packs, err := db.GetPacks(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return version, packs, nil
+}
+
+// etag returns the ETag header value of a version of the packs.
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// parseETags returns the versions listed by an If-Match or If-None-Match header, and whether it is "*".
+// Weak tags, eg. W/"3", are only kept when weak is set: If-Match compares the tags strongly,
+// so a weak one never matches there. Tags that aren't versions are skipped.
+func parseETags(header string, weak bool) ([]int, bool) {
+	var versions []int
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return nil, true
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+
+		if version, err := strconv.Atoi(tag[1 : len(tag)-1]); err == nil && version >= 0 {
+			versions = append(versions, version)
+		}
+	}
+
+	return versions, false
+}
+
+// writePacks writes the packs with their version, in the body and in the ETag header.
+func writePacks(w http.ResponseWriter, status int, version int, packs []storage.Pack) {
+	w.Header().Set("ETag", etag(version))
+	utils.WriteOutput(w, status, PacksResponse{Status: "success", Version: version, Packs: packs})
 }
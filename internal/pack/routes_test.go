@@ -2,6 +2,7 @@ package pack
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,25 +10,89 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"reparttask/storage"
+	"reparttask/storage/memory"
 	"testing"
+	"time"
 )
 
 type DbMock struct {
-	data []int
-	err  error
+	data   []int
+	levels []storage.Level
+	err    error
 }
 
 func NewDbMock(dt []int, err error) *DbMock {
 	return &DbMock{data: dt, err: err}
 }
 
-func (db *DbMock) AddPacks(size []int) error { return db.err }
+func (db *DbMock) AddPacks(ctx context.Context, packs []storage.Pack) error {
+	if db.err != nil {
+		return db.err
+	}
+
+	for _, pack := range packs {
+		db.data = append(db.data, pack.Size)
+	}
+	return nil
+}
+
+func (db *DbMock) RemovePack(ctx context.Context, size int) error { return db.err }
+
+func (db *DbMock) GetPacks(ctx context.Context) ([]storage.Pack, error) {
+	var packs []storage.Pack
+	for _, size := range db.data {
+		packs = append(packs, storage.Pack{Size: size})
+	}
+	return packs, nil
+}
+
+func (db *DbMock) RemovePacks(ctx context.Context) error { return db.err }
+
+func (db *DbMock) ReplacePacks(ctx context.Context, packs []storage.Pack, version int) error {
+	if db.err != nil {
+		return db.err
+	}
+
+	db.data = storage.Sizes(packs)
+	return nil
+}
+
+func (db *DbMock) ReservePacks(ctx context.Context, packs map[int]int) error { return db.err }
+
+func (db *DbMock) SetLevels(ctx context.Context, levels []storage.Level) error {
+	if db.err != nil {
+		return db.err
+	}
 
-func (db *DbMock) RemovePack(size int) error { return db.err }
+	db.levels = levels
+	return nil
+}
+
+func (db *DbMock) GetLevels(ctx context.Context) ([]storage.Level, error) { return db.levels, nil }
+
+func (db *DbMock) History(ctx context.Context) ([]storage.PackSet, error) { return nil, db.err }
+
+func (db *DbMock) PackSet(ctx context.Context, version int) (storage.PackSet, error) {
+	return storage.PackSet{}, db.err
+}
+
+func (db *DbMock) Rollback(ctx context.Context, version int) error { return db.err }
+
+func (db *DbMock) SchedulePacks(ctx context.Context, packs []storage.Pack, from time.Time) (storage.Schedule, error) {
+	return storage.Schedule{}, db.err
+}
 
-func (db *DbMock) GetPacks() []int { return nil }
+func (db *DbMock) Schedules(ctx context.Context) ([]storage.Schedule, error) { return nil, db.err }
 
-func (db *DbMock) RemovePacks() {}
+func (db *DbMock) CancelSchedule(ctx context.Context, id int) error { return db.err }
+
+func (db *DbMock) ApplySchedules(ctx context.Context) (int, error) { return 0, db.err }
+
+func (db *DbMock) ActivePackSet(ctx context.Context, at time.Time) (storage.PackSet, error) {
+	packs, err := db.GetPacks(ctx)
+	return storage.PackSet{Packs: packs}, err
+}
 
 func TestHandler_handleAddPacks(t *testing.T) {
 	type testCaseInput struct {
@@ -36,6 +101,7 @@ func TestHandler_handleAddPacks(t *testing.T) {
 	}
 	type testCaseOutput struct {
 		status int
+		packs  []storage.Pack
 		err    error
 	}
 	type testCase struct {
@@ -53,29 +119,103 @@ func TestHandler_handleAddPacks(t *testing.T) {
 			},
 			expected: testCaseOutput{
 				status: http.StatusCreated,
+				packs:  []storage.Pack{{Size: 200}},
+				err:    nil,
+			},
+		},
+		{
+			name: "test happy flow for adding new packs with cost, no error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{100}, nil),
+				requestPayload: SizePayload{Packs: []storage.Pack{{Size: 200, Cost: cost(15)}}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusCreated,
+				packs:  []storage.Pack{{Size: 100}, {Size: 200}},
 				err:    nil,
 			},
 		},
+		{
+			name: "test adding empty payload, error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{}, nil),
+				requestPayload: SizePayload{},
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    fmt.Errorf("pack size must be positive"),
+			},
+		},
 		{
 			name: "test adding pack with value 0, error returned",
 			input: testCaseInput{
-				dbMock:         NewDbMock([]int{}, fmt.Errorf("an error has occurred")),
+				dbMock:         NewDbMock([]int{}, nil),
 				requestPayload: SizePayload{Sizes: []int{0}},
 			},
 			expected: testCaseOutput{
-				status: http.StatusInternalServerError,
-				err:    fmt.Errorf("an error has occurred"),
+				status: http.StatusBadRequest,
+				err:    fmt.Errorf("pack size must be positive 0"),
 			},
 		},
 		{
 			name: "test adding pack with value -1, error returned",
 			input: testCaseInput{
-				dbMock:         NewDbMock([]int{}, fmt.Errorf("an error has occurred")),
+				dbMock:         NewDbMock([]int{}, nil),
 				requestPayload: SizePayload{Sizes: []int{-1}},
 			},
 			expected: testCaseOutput{
-				status: http.StatusInternalServerError,
-				err:    fmt.Errorf("an error has occurred"),
+				status: http.StatusBadRequest,
+				err:    fmt.Errorf("pack size must be positive -1"),
+			},
+		},
+		{
+			name: "test happy flow for adding a pack with metadata, no error returned",
+			input: testCaseInput{
+				dbMock: NewDbMock([]int{}, nil),
+				requestPayload: SizePayload{Packs: []storage.Pack{{
+					Size:       250,
+					Name:       "Small box",
+					Barcode:    "4006381333931",
+					Dimensions: &storage.Dimensions{Length: 300, Width: 200, Height: 100},
+					TareWeight: 120,
+				}}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusCreated,
+				packs:  []storage.Pack{{Size: 250}},
+			},
+		},
+		{
+			name: "test adding pack with invalid barcode, error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{}, nil),
+				requestPayload: SizePayload{Packs: []storage.Pack{{Size: 250, Barcode: "not a barcode"}}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New(`pack barcode must be 1 to 64 letters, digits or '-' "not a barcode"`),
+			},
+		},
+		{
+			name: "test adding pack with invalid dimensions, error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{}, nil),
+				requestPayload: SizePayload{Packs: []storage.Pack{{Size: 250, Dimensions: &storage.Dimensions{Length: 300}}}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("pack dimensions must be positive 300x0x0"),
+			},
+		},
+		{
+			name: "test adding pack with negative tare weight, error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{}, nil),
+				requestPayload: SizePayload{Packs: []storage.Pack{{Size: 250, TareWeight: -1}}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("pack tare weight must not be negative -1"),
 			},
 		},
 		{
@@ -89,12 +229,23 @@ func TestHandler_handleAddPacks(t *testing.T) {
 				err:    errors.New("an error has occurred"),
 			},
 		},
+		{
+			name: "test timeout adding pack to DB, error returned",
+			input: testCaseInput{
+				dbMock:         NewDbMock([]int{}, context.DeadlineExceeded),
+				requestPayload: SizePayload{Sizes: []int{1}},
+			},
+			expected: testCaseOutput{
+				status: http.StatusGatewayTimeout,
+				err:    errors.New("request timed out"),
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := &Handler{
-				db: tt.input.dbMock,
+				products: storage.NewProducts(tt.input.dbMock, nil),
 			}
 
 			payload, _ := json.Marshal(&tt.input.requestPayload)
@@ -119,6 +270,15 @@ func TestHandler_handleAddPacks(t *testing.T) {
 				}
 
 				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+			} else {
+				var data PacksResponse
+				err = json.Unmarshal(body, &data)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, "success", data.Status)
+				assert.Equal(t, tt.expected.packs, data.Packs)
 			}
 
 			assert.Equal(t, tt.expected.status, resp.StatusCode)
@@ -186,6 +346,17 @@ func TestHandler_handleRemovePack(t *testing.T) {
 				err:    fmt.Errorf("please provide a numeric value"),
 			},
 		},
+		{
+			name: "test removing unknown size, not found returned",
+			input: testCaseInput{
+				dbMock: NewDbMock([]int{400}, storage.Errorf(storage.ErrNotFound, "size 200 not found")),
+				size:   "200",
+			},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				err:    errors.New("size 200 not found"),
+			},
+		},
 		{
 			name: "test error removing value from DB, error returned",
 			input: testCaseInput{
@@ -202,7 +373,7 @@ func TestHandler_handleRemovePack(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			h := &Handler{
-				db: tt.input.dbMock,
+				products: storage.NewProducts(tt.input.dbMock, nil),
 			}
 
 			req := httptest.NewRequest(http.MethodDelete, "/pack", nil)
@@ -233,3 +404,888 @@ func TestHandler_handleRemovePack(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_handleSetLevels(t *testing.T) {
+	type testCaseInput struct {
+		dbMock  *DbMock
+		payload string
+	}
+	type testCaseOutput struct {
+		status int
+		levels []storage.Level
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	levels := []storage.Level{
+		{Name: "carton", Capacities: []int{10, 20}},
+		{Name: "pallet", Capacities: []int{40}},
+	}
+
+	tests := []testCase{
+		{
+			name: "test happy flow for setting levels, no error returned",
+			input: testCaseInput{
+				dbMock:  NewDbMock([]int{}, nil),
+				payload: `{"levels":[{"name":"carton","capacities":[10,20]},{"name":"pallet","capacities":[40]}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				levels: levels,
+			},
+		},
+		{
+			name: "test level without capacity, error returned",
+			input: testCaseInput{
+				dbMock:  NewDbMock([]int{}, nil),
+				payload: `{"levels":[{"name":"carton","capacities":[]}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New(`level "carton" must have at least one capacity`),
+			},
+		},
+		{
+			name: "test level with negative capacity, error returned",
+			input: testCaseInput{
+				dbMock:  NewDbMock([]int{}, nil),
+				payload: `{"levels":[{"name":"carton","capacities":[-1]}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New(`level "carton" capacity must be positive -1`),
+			},
+		},
+		{
+			name: "test level without name, error returned",
+			input: testCaseInput{
+				dbMock:  NewDbMock([]int{}, nil),
+				payload: `{"levels":[{"capacities":[10]}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("level name must not be empty"),
+			},
+		},
+		{
+			name: "test error setting levels in DB, error returned",
+			input: testCaseInput{
+				dbMock:  NewDbMock([]int{}, errors.New("an error has occurred")),
+				payload: `{"levels":[{"name":"carton","capacities":[10]}]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusInternalServerError,
+				err:    errors.New("an error has occurred"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHandler(storage.NewProducts(tt.input.dbMock, nil))
+
+			req := httptest.NewRequest(http.MethodPut, "/levels", bytes.NewBufferString(tt.input.payload))
+
+			w := httptest.NewRecorder()
+			h.handleSetLevels(w, req)
+
+			resp := w.Result()
+			body, err := io.ReadAll(resp.Body)
+			defer resp.Body.Close()
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.status, resp.StatusCode)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				err = json.Unmarshal(body, &e)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, errors.New(e["error"]), tt.expected.err)
+				return
+			}
+
+			var data LevelsPayload
+			err = json.Unmarshal(body, &data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.levels, data.Levels)
+
+			// the stored levels are returned by the get endpoint.
+			w = httptest.NewRecorder()
+			h.handleGetLevels(w, httptest.NewRequest(http.MethodGet, "/levels", nil))
+
+			data = LevelsPayload{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &data))
+			assert.Equal(t, tt.expected.levels, data.Levels)
+		})
+	}
+}
+
+func TestHandler_products(t *testing.T) {
+	def := NewDbMock([]int{250}, nil)
+	products := storage.NewProducts(def, func(string) (storage.Storage, error) { return NewDbMock([]int{}, nil), nil })
+
+	router := http.NewServeMux()
+	NewHandler(products).RegisterRoutes(router)
+
+	serve := func(method, path, payload string) (int, PacksResponse) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, bytes.NewBufferString(payload)))
+
+		var data PacksResponse
+		json.Unmarshal(w.Body.Bytes(), &data)
+		return w.Code, data
+	}
+
+	// an unknown product can't be read before packs are added.
+	status, _ := serve(http.MethodGet, "/products/SKU-1/packs", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, _ = serve(http.MethodDelete, "/products/SKU-1/packs/23", "")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	status, data := serve(http.MethodPost, "/products/SKU-1/packs", `{"sizes":[23,31]}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, []storage.Pack{{Size: 23}, {Size: 31}}, data.Packs)
+
+	status, data = serve(http.MethodGet, "/products/SKU-1/packs", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []storage.Pack{{Size: 23}, {Size: 31}}, data.Packs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/SKU-1/packs/31", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"size":31}`, w.Body.String())
+
+	status, _ = serve(http.MethodDelete, "/products/SKU-1/packs/23", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, _ = serve(http.MethodDelete, "/products/SKU-1/packs", "")
+	assert.Equal(t, http.StatusOK, status)
+
+	// the default product is left untouched.
+	assert.Equal(t, []int{250}, def.data)
+	status, data = serve(http.MethodGet, "/products/default/packs", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []storage.Pack{{Size: 250}}, data.Packs)
+
+	status, data = serve(http.MethodGet, "/packs", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []storage.Pack{{Size: 250}}, data.Packs)
+
+	// the unscoped endpoints use the default product.
+	status, _ = serve(http.MethodPost, "/pack", `{"sizes":[500]}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.Equal(t, []int{250, 500}, def.data)
+
+	status, _ = serve(http.MethodPost, "/products/bad%20sku/packs", `{"sizes":[1]}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	assert.Equal(t, []string{"SKU-1", storage.DefaultSKU}, products.Products())
+}
+
+func TestHandler_handleGetPacks(t *testing.T) {
+	type testCaseInput struct {
+		packs       []storage.Pack
+		sku         string
+		ifNoneMatch string
+	}
+	type testCaseOutput struct {
+		status  int
+		packs   []storage.Pack
+		version int
+		err     error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	stock := 20
+	small := storage.Pack{Size: 250, Cost: cost(10), Name: "Small box", Barcode: "SB-250", TareWeight: 120}
+	large := storage.Pack{Size: 5000, Cost: cost(100), Stock: &stock, Dimensions: &storage.Dimensions{Length: 600, Width: 400, Height: 400}}
+
+	tests := []testCase{
+		{
+			name: "test happy flow for listing packs, sorted with metadata",
+			input: testCaseInput{
+				packs: []storage.Pack{large, small},
+			},
+			expected: testCaseOutput{
+				status:  http.StatusOK,
+				packs:   []storage.Pack{small, large},
+				version: 1,
+			},
+		},
+		{
+			name:  "test listing without packs, empty list returned",
+			input: testCaseInput{},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []storage.Pack{},
+			},
+		},
+		{
+			name: "test listing with the current version, not modified returned",
+			input: testCaseInput{
+				packs:       []storage.Pack{small, large},
+				ifNoneMatch: `"1"`,
+			},
+			expected: testCaseOutput{
+				status:  http.StatusNotModified,
+				version: 1,
+			},
+		},
+		{
+			name: "test listing with a weak current version in a list, not modified returned",
+			input: testCaseInput{
+				packs:       []storage.Pack{small, large},
+				ifNoneMatch: `"0", W/"1"`,
+			},
+			expected: testCaseOutput{
+				status:  http.StatusNotModified,
+				version: 1,
+			},
+		},
+		{
+			name: "test listing with an old version, packs returned",
+			input: testCaseInput{
+				packs:       []storage.Pack{small, large},
+				ifNoneMatch: `"0"`,
+			},
+			expected: testCaseOutput{
+				status:  http.StatusOK,
+				packs:   []storage.Pack{small, large},
+				version: 1,
+			},
+		},
+		{
+			name: "test listing unknown product, error returned",
+			input: testCaseInput{
+				sku: "SKU-1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				err:    errors.New(`product "SKU-1" not found`),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			if err := db.AddPacks(context.Background(), tt.input.packs); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewHandler(storage.NewProducts(db, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/packs", nil)
+			req.SetPathValue("sku", tt.input.sku)
+			if tt.input.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tt.input.ifNoneMatch)
+			}
+
+			w := httptest.NewRecorder()
+			h.handleGetPacks(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+				return
+			}
+
+			// the version of the stored packs is sent in the header either way.
+			assert.Equal(t, fmt.Sprintf(`"%d"`, tt.expected.version), w.Header().Get("ETag"))
+			if tt.expected.status == http.StatusNotModified {
+				assert.Empty(t, w.Body.Bytes())
+				return
+			}
+
+			var data PacksResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, tt.expected.packs, data.Packs)
+			assert.Equal(t, tt.expected.version, data.Version)
+		})
+	}
+}
+
+func TestHandler_handleGetPack(t *testing.T) {
+	type testCaseInput struct {
+		size string
+	}
+	type testCaseOutput struct {
+		status int
+		pack   storage.Pack
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	small := storage.Pack{Size: 250, Cost: cost(10), Name: "Small box", Barcode: "SB-250", TareWeight: 120}
+
+	tests := []testCase{
+		{
+			name: "test happy flow for reading a pack, no error returned",
+			input: testCaseInput{
+				size: "250",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				pack:   small,
+			},
+		},
+		{
+			name: "test reading unknown size, error returned",
+			input: testCaseInput{
+				size: "750",
+			},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				err:    errors.New("size 750 not found"),
+			},
+		},
+		{
+			name: "test reading size larger than every pack, error returned",
+			input: testCaseInput{
+				size: "5001",
+			},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				err:    errors.New("size 5001 not found"),
+			},
+		},
+		{
+			name: "test reading negative size, error returned",
+			input: testCaseInput{
+				size: "-1",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("you must provide a positive value"),
+			},
+		},
+		{
+			name: "test reading invalid size, error returned",
+			input: testCaseInput{
+				size: "test",
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("please provide a numeric value"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			if err := db.AddPacks(context.Background(), []storage.Pack{{Size: 5000}, small, {Size: 500}}); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewHandler(storage.NewProducts(db, nil))
+
+			req := httptest.NewRequest(http.MethodGet, "/pack/{size}", nil)
+			req.SetPathValue("size", tt.input.size)
+
+			w := httptest.NewRecorder()
+			h.handleGetPack(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+				return
+			}
+
+			var pack storage.Pack
+			if err := json.Unmarshal(w.Body.Bytes(), &pack); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected.pack, pack)
+		})
+	}
+}
+
+func TestHandler_handleReplacePacks(t *testing.T) {
+	type testCaseInput struct {
+		payload string
+		ifMatch string
+	}
+	type testCaseOutput struct {
+		status int
+		packs  []storage.Pack
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	// the stored packs are the first version.
+	stored := []storage.Pack{{Size: 250, Cost: cost(10)}, {Size: 500, Cost: cost(12)}}
+	current := `"1"`
+
+	tests := []testCase{
+		{
+			name: "test happy flow for replacing packs, no error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[23],"packs":[{"size":31,"cost":5}]}`,
+				ifMatch: current,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []storage.Pack{{Size: 23}, {Size: 31, Cost: cost(5)}},
+			},
+		},
+		{
+			name: "test replacing packs without version, no error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []storage.Pack{{Size: 1000}},
+			},
+		},
+		{
+			name: "test replacing packs with any version, no error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000]}`,
+				ifMatch: "*",
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []storage.Pack{{Size: 1000}},
+			},
+		},
+		{
+			name: "test replacing packs with the current version in a list, no error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000]}`,
+				ifMatch: `"0", "1"`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  []storage.Pack{{Size: 1000}},
+			},
+		},
+		{
+			name: "test replacing packs with an old version, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000]}`,
+				ifMatch: `"0"`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusConflict,
+				packs:  stored,
+				err:    errors.New("packs were changed, the current version is 1"),
+			},
+		},
+		{
+			name: "test replacing packs with a weak version, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000]}`,
+				ifMatch: `W/"1"`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusConflict,
+				packs:  stored,
+				err:    errors.New("packs were changed, the current version is 1"),
+			},
+		},
+		{
+			name: "test replacing with invalid pack, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[1000, 0]}`,
+				ifMatch: current,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				packs:  stored,
+				err:    errors.New("pack size must be positive 0"),
+			},
+		},
+		{
+			name: "test replacing with no packs, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				packs:  stored,
+				err:    errors.New("pack size must be positive"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			if err := db.AddPacks(context.Background(), stored); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewHandler(storage.NewProducts(db, nil))
+
+			req := httptest.NewRequest(http.MethodPut, "/packs", bytes.NewBufferString(tt.input.payload))
+			if tt.input.ifMatch != "" {
+				req.Header.Set("If-Match", tt.input.ifMatch)
+			}
+
+			w := httptest.NewRecorder()
+			h.handleReplacePacks(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+			} else {
+				var data PacksResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.packs, data.Packs)
+				assert.Equal(t, 2, data.Version)
+				assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+			}
+
+			// a failed replace leaves the packs as they were.
+			packs, err := db.GetPacks(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected.packs, packs)
+		})
+	}
+}
+
+func TestHandler_handleGetHistory(t *testing.T) {
+	db := memory.NewMemDB()
+	h := NewHandler(storage.NewProducts(db, nil))
+
+	router := http.NewServeMux()
+	h.RegisterRoutes(router)
+
+	req := httptest.NewRequest(http.MethodPost, "/pack", bytes.NewBufferString(`{"sizes":[250,500]}`))
+	req.Header.Set("X-Actor", "alice")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodDelete, "/pack/500", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/packs/history", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var data HistoryResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, data.Versions, 2)
+	assert.Equal(t, "alice", data.Versions[0].Actor)
+	assert.Equal(t, storage.Diff{Added: []storage.Pack{{Size: 250}, {Size: 500}}}, data.Versions[0].Diff)
+	assert.Equal(t, "", data.Versions[1].Actor)
+	assert.Equal(t, storage.ChangeRemove, data.Versions[1].Change)
+	assert.Equal(t, []storage.Pack{{Size: 250}}, data.Versions[1].Packs)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/products/SKU-1/packs/history", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestHandler_handleRollback(t *testing.T) {
+	type testCaseInput struct {
+		version string
+	}
+	type testCaseOutput struct {
+		status int
+		packs  []storage.Pack
+		err    error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	first := []storage.Pack{{Size: 250, Cost: cost(10)}, {Size: 500, Cost: cost(12)}}
+	second := []storage.Pack{{Size: 1000, Cost: cost(20)}}
+
+	tests := []testCase{
+		{
+			name:  "test happy flow for rolling back, no error returned",
+			input: testCaseInput{version: "1"},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  first,
+			},
+		},
+		{
+			name:  "test rolling back to the current version, no error returned",
+			input: testCaseInput{version: "2"},
+			expected: testCaseOutput{
+				status: http.StatusOK,
+				packs:  second,
+			},
+		},
+		{
+			name:  "test rolling back to an unknown version, error returned",
+			input: testCaseInput{version: "3"},
+			expected: testCaseOutput{
+				status: http.StatusNotFound,
+				packs:  second,
+				err:    errors.New("version 3 not found"),
+			},
+		},
+		{
+			name:  "test rolling back to an invalid version, error returned",
+			input: testCaseInput{version: "first"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				packs:  second,
+				err:    errors.New("the version must be a positive number"),
+			},
+		},
+		{
+			name:  "test rolling back to a negative version, error returned",
+			input: testCaseInput{version: "-1"},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				packs:  second,
+				err:    errors.New("the version must be a positive number"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			if err := db.AddPacks(context.Background(), first); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.ReplacePacks(context.Background(), second, storage.AnyVersion); err != nil {
+				t.Fatal(err)
+			}
+
+			h := NewHandler(storage.NewProducts(db, nil))
+
+			req := httptest.NewRequest(http.MethodPost, "/packs/rollback/{version}", nil)
+			req.SetPathValue("version", tt.input.version)
+
+			w := httptest.NewRecorder()
+			h.handleRollback(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+			} else {
+				var data PacksResponse
+				if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.packs, data.Packs)
+			}
+
+			packs, err := db.GetPacks(context.Background())
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected.packs, packs)
+		})
+	}
+}
+
+func TestHandler_handleSchedulePacks(t *testing.T) {
+	type testCaseInput struct {
+		payload string
+	}
+	type testCaseOutput struct {
+		status   int
+		schedule storage.Schedule
+		err      error
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []testCase{
+		{
+			name: "test happy flow for scheduling packs, no error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[500],"packs":[{"size":300,"cost":5}],"effective_from":"2026-10-02T00:00:00Z"}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusCreated,
+				schedule: storage.Schedule{
+					ID:            1,
+					EffectiveFrom: now.Add(24 * time.Hour),
+					CreatedAt:     now,
+					Actor:         "alice",
+					Packs:         []storage.Pack{{Size: 300, Cost: cost(5)}, {Size: 500}},
+				},
+			},
+		},
+		{
+			name: "test scheduling packs without effective time, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[500]}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("effective_from must be set"),
+			},
+		},
+		{
+			name: "test scheduling packs in the past, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[500],"effective_from":"2026-09-30T00:00:00Z"}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("the effective time must be in the future"),
+			},
+		},
+		{
+			name: "test scheduling invalid packs, error returned",
+			input: testCaseInput{
+				payload: `{"sizes":[500, 0],"effective_from":"2026-10-02T00:00:00Z"}`,
+			},
+			expected: testCaseOutput{
+				status: http.StatusBadRequest,
+				err:    errors.New("pack size must be positive 0"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := memory.NewMemDB()
+			db.SetClock(func() time.Time { return now })
+
+			h := NewHandler(storage.NewProducts(db, nil))
+
+			req := httptest.NewRequest(http.MethodPost, "/packs/schedules", bytes.NewBufferString(tt.input.payload))
+			req.Header.Set("X-Actor", "alice")
+
+			w := httptest.NewRecorder()
+			h.handleSchedulePacks(w, req)
+
+			assert.Equal(t, tt.expected.status, w.Code)
+
+			if tt.expected.err != nil {
+				e := map[string]string{}
+				if err := json.Unmarshal(w.Body.Bytes(), &e); err != nil {
+					t.Fatal(err)
+				}
+
+				assert.Equal(t, tt.expected.err, errors.New(e["error"]))
+				return
+			}
+
+			var schedule storage.Schedule
+			if err := json.Unmarshal(w.Body.Bytes(), &schedule); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.expected.schedule, schedule)
+		})
+	}
+}
+
+func TestHandler_schedules(t *testing.T) {
+	db := memory.NewMemDB()
+	h := NewHandler(storage.NewProducts(db, nil))
+
+	router := http.NewServeMux()
+	h.RegisterRoutes(router)
+
+	from := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	for _, size := range []string{"300", "400"} {
+		payload := fmt.Sprintf(`{"sizes":[%s],"effective_from":%q}`, size, from)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/packs/schedules", bytes.NewBufferString(payload)))
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/packs/schedules/1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/packs/schedules/1", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/packs/schedules/first", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/packs/schedules", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var data SchedulesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &data); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Len(t, data.Schedules, 1)
+	assert.Equal(t, 2, data.Schedules[0].ID)
+	assert.Equal(t, []storage.Pack{{Size: 400}}, data.Schedules[0].Packs)
+
+	// nothing is applied before the effective time.
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/packs", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"packs":[]`)
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
+}
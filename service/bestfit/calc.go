@@ -1,58 +1,85 @@
 package bestfit
 
 import (
+	"context"
+	"reparttask/service"
 	"sort"
 )
 
-type Calc struct {
-	bestFit map[int]int
-	bestSum int
-}
+// Strategy is the name reported in results computed by Calc.
+const Strategy = "bestfit"
+
+// Calc holds no state between calls, so a single instance can be shared by
+// concurrent requests.
+type Calc struct{}
 
 func NewCalc() *Calc {
-	return &Calc{
-		bestFit: make(map[int]int),
-		bestSum: 0,
-	}
+	return &Calc{}
+}
+
+// checkEvery is how many combinations are explored between two context checks.
+const checkEvery = 1 << 12
+
+// search keeps the best solution found during a single CalculatePacks call.
+type search struct {
+	ctx     context.Context
+	visited int
+	err     error
+	bestFit map[int]int
+	bestSum int
 }
 
 // CalculatePacks function is used to find the best fit for the target value
-func (c *Calc) CalculatePacks(packs []int, target int) map[int]int {
-	// clear any values stored previously
-	c.reset()
+func (c *Calc) CalculatePacks(ctx context.Context, packs []int, target int) (service.Result, error) {
+	if len(packs) == 0 {
+		return service.NewResult(Strategy, target, nil), nil
+	}
 
-	// sort packs in ascending order.
-	sort.Ints(packs)
+	// sort a copy of the packs in ascending order, the caller's slice is read-only.
+	sorted := make([]int, len(packs))
+	copy(sorted, packs)
+	sort.Ints(sorted)
 
 	// start processing combinations
-	c.findCombinations(packs, map[int]int{}, 0, 0, target)
+	s := &search{ctx: ctx, bestFit: make(map[int]int)}
+	s.findCombinations(sorted, map[int]int{}, 0, 0, target)
+	if s.err != nil {
+		return service.Result{}, s.err
+	}
 
 	// if there is a reminder, then we'll append it to the smaller pack
-	rem := target - c.bestSum
+	rem := target - s.bestSum
 	if rem > 0 {
-		c.bestFit[packs[0]]++
+		s.bestFit[sorted[0]]++
 	}
 
 	// if possible, combine small packs into larger ones
-	result := combinePacks(c.bestFit, packs)
+	result := combinePacks(s.bestFit, sorted)
 
-	return result
+	return service.NewResult(Strategy, target, result), nil
 }
 
-func (c *Calc) findCombinations(packs []int, current map[int]int, currentSum int, start int, target int) {
+func (s *search) findCombinations(packs []int, current map[int]int, currentSum int, start int, target int) {
 	// stop condition
-	if currentSum > target {
+	if currentSum > target || s.err != nil {
+		return
+	}
+
+	// stop the whole search once the context is done.
+	s.visited++
+	if s.visited%checkEvery == 0 && s.ctx.Err() != nil {
+		s.err = s.ctx.Err()
 		return
 	}
 
 	// check to see if we found a better solution.
-	if currentSum >= c.bestSum {
-		c.bestSum = currentSum        // save the new best sum
-		c.bestFit = make(map[int]int) // reset so that we store only best solution.
+	if currentSum >= s.bestSum {
+		s.bestSum = currentSum        // save the new best sum
+		s.bestFit = make(map[int]int) // reset so that we store only best solution.
 
 		// store new solution.
 		for k, v := range current {
-			c.bestFit[k] = v
+			s.bestFit[k] = v
 		}
 	}
 
@@ -67,19 +94,17 @@ func (c *Calc) findCombinations(packs []int, current map[int]int, currentSum int
 		// increment the current pack size count and explore new combination
 		next[packs[i]]++
 
-		c.findCombinations(packs, next, currentSum+packs[i], i, target)
+		s.findCombinations(packs, next, currentSum+packs[i], i, target)
 	}
 }
 
-func (c *Calc) reset() {
-	c.bestFit = make(map[int]int)
-	c.bestSum = 0
-}
-
 // combinePacks helps accommodate smaller packs into larger ones.
 func combinePacks(m map[int]int, packs []int) map[int]int {
-	// sort available packs in descending order
-	sort.Sort(sort.Reverse(sort.IntSlice(packs)))
+	// sort a copy of the available packs in descending order
+	sorted := make([]int, len(packs))
+	copy(sorted, packs)
+	sort.Sort(sort.Reverse(sort.IntSlice(sorted)))
+	packs = sorted
 
 	for i := 0; i < len(packs); i++ {
 		for j := i + 1; j < len(packs); j++ {
@@ -1,8 +1,11 @@
 package bestfit
 
 import (
+	"context"
+	"errors"
 	"log"
 	"testing"
+	"time"
 )
 
 func Test_CalculatePacks(t *testing.T) {
@@ -148,7 +151,12 @@ func Test_CalculatePacks(t *testing.T) {
 			t.Parallel()
 
 			c := NewCalc()
-			got := c.CalculatePacks(tt.input.input, tt.input.orderQuantity)
+			res, err := c.CalculatePacks(context.Background(), tt.input.input, tt.input.orderQuantity)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := res.Map()
 
 			log.Println(got)
 			// check expected values one by one
@@ -219,3 +227,29 @@ func Test_combinePacks(t *testing.T) {
 		})
 	}
 }
+
+func Test_CalculatePacksInputUnchanged(t *testing.T) {
+	packs := []int{5000, 250, 2000, 500, 1000}
+	want := []int{5000, 250, 2000, 500, 1000}
+
+	c := NewCalc()
+	c.CalculatePacks(context.Background(), packs, 12001)
+
+	for i := range want {
+		if packs[i] != want[i] {
+			t.Fatalf("input packs were modified: got %v, want %v", packs, want)
+		}
+	}
+}
+
+func Test_CalculatePacksTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// small co-prime packs make the recursive search explode for this order size.
+	c := NewCalc()
+	_, err := c.CalculatePacks(ctx, []int{23, 31, 53}, 50_000)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"hash/fnv"
+	"reparttask/service"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// Stats holds the cache counters.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Size      int    `json:"size"`
+	Capacity  int    `json:"capacity"`
+}
+
+// key identifies a calculation by the pack set fingerprint and the order quantity.
+type key struct {
+	fingerprint uint64
+	quantity    int
+}
+
+type item struct {
+	key    key
+	result service.Result
+}
+
+// Calc is a bounded LRU cache in front of a service.Calculator.
+// Only CalculatePacks results are cached, stock limited, alternative and
+// tolerant calculations are forwarded to the wrapped calculator.
+type Calc struct {
+	calc     service.Calculator
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	items map[key]*list.Element
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+// New returns a cache holding up to capacity results of calc.
+func New(calc service.Calculator, capacity int) *Calc {
+	return &Calc{
+		calc:     calc,
+		capacity: capacity,
+		order:    list.New(),
+		items:    map[key]*list.Element{},
+	}
+}
+
+func (c *Calc) CalculatePacks(ctx context.Context, packs []int, orderQuantity int) (service.Result, error) {
+	k := key{fingerprint: Fingerprint(packs), quantity: orderQuantity}
+
+	if result, ok := c.get(k); ok {
+		c.hits.Add(1)
+		return result, nil
+	}
+	c.misses.Add(1)
+
+	result, err := c.calc.CalculatePacks(ctx, packs, orderQuantity)
+	if err != nil {
+		// errors, like a cancelled context, are not cached.
+		return result, err
+	}
+
+	c.put(k, result)
+	return copyResult(result), nil
+}
+
+func (c *Calc) CalculateBoundedPacks(ctx context.Context, packs []int, stock map[int]int, orderQuantity int) (service.Result, error) {
+	bounded, ok := c.calc.(service.BoundedCalculator)
+	if !ok {
+		return service.Result{}, service.ErrStockNotSupported
+	}
+
+	return bounded.CalculateBoundedPacks(ctx, packs, stock, orderQuantity)
+}
+
+func (c *Calc) CalculateAlternatives(ctx context.Context, packs []int, orderQuantity int, k int) ([]service.Result, error) {
+	alternatives, ok := c.calc.(service.AlternativesCalculator)
+	if !ok {
+		return nil, service.ErrAlternativesNotSupported
+	}
+
+	return alternatives.CalculateAlternatives(ctx, packs, orderQuantity, k)
+}
+
+func (c *Calc) Explain(winner, runnerUp service.Result) string {
+	alternatives, ok := c.calc.(service.AlternativesCalculator)
+	if !ok {
+		return ""
+	}
+
+	return alternatives.Explain(winner, runnerUp)
+}
+
+// WithTolerance returns the wrapped calculator with the tolerance applied,
+// results calculated with a tolerance are not cached.
+func (c *Calc) WithTolerance(t service.Tolerance) (service.Calculator, error) {
+	tolerant, ok := c.calc.(service.TolerantCalculator)
+	if !ok {
+		return nil, service.ErrModeNotSupported
+	}
+
+	return tolerant.WithTolerance(t)
+}
+
+// Purge drops every cached result, it is called whenever the pack set changes.
+func (c *Calc) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.order.Init()
+	c.items = map[key]*list.Element{}
+}
+
+// Stats returns the current counters.
+func (c *Calc) Stats() Stats {
+	c.mu.Lock()
+	size := c.order.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Size:      size,
+		Capacity:  c.capacity,
+	}
+}
+
+func (c *Calc) get(k key) (service.Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[k]
+	if !ok {
+		return service.Result{}, false
+	}
+
+	c.order.MoveToFront(el)
+	return copyResult(el.Value.(*item).result), true
+}
+
+func (c *Calc) put(k key, result service.Result) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[k]; ok {
+		el.Value.(*item).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.items[k] = c.order.PushFront(&item{key: k, result: result})
+
+	// drop the least recently used result.
+	if c.order.Len() > c.capacity {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*item).key)
+		c.evictions.Add(1)
+	}
+}
+
+// Fingerprint hashes the sorted, distinct pack sizes, so the order in which
+// packs were added doesn't matter.
+func Fingerprint(packs []int) uint64 {
+	sizes := make([]int, len(packs))
+	copy(sizes, packs)
+	sort.Ints(sizes)
+
+	h := fnv.New64a()
+	for i, size := range sizes {
+		if i > 0 && size == sizes[i-1] {
+			continue
+		}
+
+		h.Write(strconv.AppendInt(nil, int64(size), 10))
+		h.Write([]byte{','})
+	}
+
+	return h.Sum64()
+}
+
+// copyResult returns a copy that doesn't share the pack lines with the cached result.
+func copyResult(result service.Result) service.Result {
+	result.Packs = append([]service.PackLine{}, result.Packs...)
+	return result
+}
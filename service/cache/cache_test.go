@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reparttask/service"
+	"reparttask/service/dp"
+	"sync/atomic"
+	"testing"
+)
+
+// countingCalc counts the calculations that reach the wrapped calculator.
+type countingCalc struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (c *countingCalc) CalculatePacks(ctx context.Context, packs []int, orderQuantity int) (service.Result, error) {
+	c.calls.Add(1)
+	if c.err != nil {
+		return service.Result{}, c.err
+	}
+
+	return dp.NewCalc().CalculatePacks(ctx, packs, orderQuantity)
+}
+
+func Test_CalculatePacks(t *testing.T) {
+	inner := &countingCalc{}
+	c := New(inner, 2)
+	ctx := context.Background()
+
+	first, err := c.CalculatePacks(ctx, []int{250, 500, 1000}, 751)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{1000: 1}, first.Map())
+
+	// the same pack set in another order is a hit.
+	second, err := c.CalculatePacks(ctx, []int{1000, 500, 250}, 751)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	// another pack set is a miss.
+	_, err = c.CalculatePacks(ctx, []int{250, 500}, 751)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+
+	assert.Equal(t, Stats{Hits: 1, Misses: 2, Size: 2, Capacity: 2}, c.Stats())
+}
+
+func Test_CalculatePacksEviction(t *testing.T) {
+	inner := &countingCalc{}
+	c := New(inner, 2)
+	ctx := context.Background()
+	packs := []int{250, 500, 1000}
+
+	c.CalculatePacks(ctx, packs, 1)
+	c.CalculatePacks(ctx, packs, 2)
+	// 1 becomes the most recently used, so 2 is evicted by 3.
+	c.CalculatePacks(ctx, packs, 1)
+	c.CalculatePacks(ctx, packs, 3)
+	assert.Equal(t, int32(3), inner.calls.Load())
+
+	c.CalculatePacks(ctx, packs, 1)
+	assert.Equal(t, int32(3), inner.calls.Load())
+
+	c.CalculatePacks(ctx, packs, 2)
+	assert.Equal(t, int32(4), inner.calls.Load())
+
+	assert.Equal(t, uint64(2), c.Stats().Evictions)
+}
+
+func Test_Purge(t *testing.T) {
+	inner := &countingCalc{}
+	c := New(inner, 10)
+	ctx := context.Background()
+
+	c.CalculatePacks(ctx, []int{250}, 1)
+	c.Purge()
+	c.CalculatePacks(ctx, []int{250}, 1)
+
+	assert.Equal(t, int32(2), inner.calls.Load())
+	assert.Equal(t, 1, c.Stats().Size)
+}
+
+func Test_CalculatePacksErrorNotCached(t *testing.T) {
+	inner := &countingCalc{err: context.Canceled}
+	c := New(inner, 10)
+	ctx := context.Background()
+
+	_, err := c.CalculatePacks(ctx, []int{250}, 1)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	inner.err = nil
+	res, err := c.CalculatePacks(ctx, []int{250}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 1}, res.Map())
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
+
+func Test_CachedResultIsCopied(t *testing.T) {
+	c := New(&countingCalc{}, 10)
+	ctx := context.Background()
+
+	first, _ := c.CalculatePacks(ctx, []int{250}, 1)
+	first.Packs[0].Quantity = 100
+
+	second, _ := c.CalculatePacks(ctx, []int{250}, 1)
+	assert.Equal(t, 1, second.Packs[0].Quantity)
+}
+
+func Test_Forwarding(t *testing.T) {
+	ctx := context.Background()
+
+	c := New(dp.NewCalc(), 10)
+	res, err := c.CalculateBoundedPacks(ctx, []int{250, 500}, map[int]int{500: 0}, 251)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 2}, res.Map())
+
+	alternatives, err := c.CalculateAlternatives(ctx, []int{250, 500}, 251, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(alternatives))
+
+	// a calculator without the optional interfaces.
+	c = New(&countingCalc{}, 10)
+	_, err = c.CalculateBoundedPacks(ctx, []int{250}, map[int]int{250: 1}, 1)
+	assert.ErrorIs(t, err, service.ErrStockNotSupported)
+
+	_, err = c.CalculateAlternatives(ctx, []int{250}, 1, 2)
+	assert.ErrorIs(t, err, service.ErrAlternativesNotSupported)
+}
+
+func Test_Fingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint([]int{250, 500, 1000}), Fingerprint([]int{1000, 250, 500, 500}))
+	assert.NotEqual(t, Fingerprint([]int{250, 500}), Fingerprint([]int{250, 5000}))
+	assert.NotEqual(t, Fingerprint([]int{25, 5}), Fingerprint([]int{255}))
+}
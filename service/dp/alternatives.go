@@ -0,0 +1,274 @@
+package dp
+
+import (
+	"context"
+	"fmt"
+	"reparttask/service"
+	"sort"
+)
+
+// maxAlternativeUnits limits the table size used to rank alternatives,
+// every total keeps up to k packings so it needs far more memory than CalculatePacks.
+const maxAlternativeUnits = 200_000
+
+// entry is one packing in the alternatives table, stored as the last pack added
+// on top of its parent packing so that packings share their common part.
+type entry struct {
+	packs  uint32
+	cost   int64
+	index  int
+	parent *entry
+}
+
+// candidate is a complete packing ranked by the objective.
+type candidate struct {
+	total int
+	e     *entry
+}
+
+// CalculateAlternatives returns up to k distinct packings for the target value,
+// best first, ranked by the calculator objective. The first one is the packing
+// CalculatePacks returns. Fewer than k are returned only when the tolerance or
+// the pack limit leave fewer packings, or when more would need too large a table.
+func (c *Calc) CalculateAlternatives(ctx context.Context, packs []int, target int, k int) ([]service.Result, error) {
+	if k <= 0 || k > service.MaxAlternatives {
+		return nil, fmt.Errorf("number of alternatives must be between 1 and %d", service.MaxAlternatives)
+	}
+
+	sizes := Normalize(packs)
+	if target <= 0 || len(sizes) == 0 {
+		return []service.Result{}, nil
+	}
+
+	g, units := Units(sizes)
+	goal, low, limit := c.span(target, g)
+
+	// dropping a pack from any packing above the bound still covers the goal,
+	// so the best packing is inside it, the bound only grows when it holds fewer than k.
+	if goal > maxAlternativeUnits {
+		return nil, service.ErrTooLarge
+	}
+	largest := units[len(units)-1]
+	bound := goal + largest
+	if bound > maxAlternativeUnits {
+		return nil, service.ErrTooLarge
+	}
+
+	// no packing reaches a total above the ceiling within the tolerance and the pack limit.
+	ceiling := maxAlternativeUnits
+	if limit >= 0 {
+		ceiling = min(ceiling, limit+1)
+	}
+	if c.maxPacks > 0 && c.maxPacks < ceiling/largest {
+		ceiling = c.maxPacks*largest + 1
+	}
+	bound = min(bound, ceiling)
+
+	t := c.newTable(sizes, units, 1)
+	t.gcd, t.target = g, target
+
+	var candidates []candidate
+	for {
+		var err error
+		candidates, err = c.alternatives(ctx, t, low, bound, k)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(candidates) >= k || bound >= ceiling {
+			break
+		}
+
+		bound = min(2*bound, ceiling)
+	}
+
+	if len(candidates) == 0 {
+		return nil, service.ErrNoSolution
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return c.lessCandidate(t, candidates[a], candidates[b])
+	})
+
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+
+	results := make([]service.Result, len(candidates))
+	for i, cand := range candidates {
+		result := map[int]int{}
+		for e := cand.e; e != nil; e = e.parent {
+			result[sizes[e.index]]++
+		}
+
+		results[i] = service.NewResult(c.strategy, target, result)
+	}
+
+	return results, nil
+}
+
+// alternatives returns the k best packings of every total from low up to the bound.
+func (c *Calc) alternatives(ctx context.Context, t *table, low, bound, k int) ([]candidate, error) {
+	// lists[total] keeps the k best packings reaching total, sizes are added one
+	// at a time so that every packing is built in a single way and stays distinct.
+	lists := make([][]*entry, bound)
+	lists[0] = []*entry{nil}
+	for i, u := range t.units {
+		for total := u; total < bound; total++ {
+			if done(ctx, total) {
+				return nil, ctx.Err()
+			}
+
+			if len(lists[total-u]) == 0 {
+				continue
+			}
+
+			merged := append([]*entry{}, lists[total]...)
+			for _, parent := range lists[total-u] {
+				e := &entry{packs: 1, cost: t.weight(i, 1), index: i, parent: parent}
+				if parent != nil {
+					e.packs += parent.packs
+					e.cost += parent.cost
+				}
+
+				merged = append(merged, e)
+			}
+
+			sort.SliceStable(merged, func(a, b int) bool {
+				return lessEntry(merged[a], merged[b])
+			})
+
+			if len(merged) > k {
+				merged = merged[:k]
+			}
+
+			lists[total] = merged
+		}
+	}
+
+	var candidates []candidate
+	for total := low; total < bound; total++ {
+		for _, e := range lists[total] {
+			if e == nil || (c.maxPacks > 0 && int(e.packs) > c.maxPacks) {
+				continue
+			}
+
+			candidates = append(candidates, candidate{total: total, e: e})
+		}
+	}
+
+	return candidates, nil
+}
+
+// lessCandidate ranks two complete packings by the objective, like CalculatePacks does.
+func (c *Calc) lessCandidate(t *table, a, b candidate) bool {
+	switch c.objective {
+	case minPacks:
+		if a.e.packs != b.e.packs {
+			return a.e.packs < b.e.packs
+		}
+	case minCost:
+		ca, cb := a.e.cost+t.surplus(a.total), b.e.cost+t.surplus(b.total)
+		if ca != cb {
+			return ca < cb
+		}
+	case minSurplus:
+		// the packing closest to the order wins, covering it on ties.
+		da, db := t.distance(a.total), t.distance(b.total)
+		if da != db {
+			return da < db
+		}
+
+		if a.total != b.total {
+			return a.total > b.total
+		}
+	}
+
+	if a.total != b.total {
+		return a.total < b.total
+	}
+
+	return lessEntry(a.e, b.e)
+}
+
+// lessEntry ranks two packings of the same total: the cheaper one, then the one
+// with fewer packs, then the one using more of the larger packs.
+func lessEntry(a, b *entry) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+
+	if a.cost != b.cost {
+		return a.cost < b.cost
+	}
+
+	if a.packs != b.packs {
+		return a.packs < b.packs
+	}
+
+	ca, cb := entryCounts(a), entryCounts(b)
+	for i := max(len(ca), len(cb)) - 1; i >= 0; i-- {
+		if countAt(ca, i) != countAt(cb, i) {
+			return countAt(ca, i) > countAt(cb, i)
+		}
+	}
+
+	return false
+}
+
+func countAt(counts []int, i int) int {
+	if i < len(counts) {
+		return counts[i]
+	}
+
+	return 0
+}
+
+// entryCounts returns the number of packs of each size index, indexes are
+// added in ascending order so the last pack added holds the largest one.
+func entryCounts(e *entry) []int {
+	if e == nil {
+		return nil
+	}
+
+	counts := make([]int, e.index+1)
+	for ; e != nil; e = e.parent {
+		counts[e.index]++
+	}
+
+	return counts
+}
+
+// Explain describes why winner ranks above runnerUp for the calculator objective.
+func (c *Calc) Explain(winner, runnerUp service.Result) string {
+	switch c.objective {
+	case minPacks:
+		if winner.TotalPacks != runnerUp.TotalPacks {
+			return fmt.Sprintf("fewer packs (%d vs %d)", winner.TotalPacks, runnerUp.TotalPacks)
+		}
+
+		if winner.SurplusItems != runnerUp.SurplusItems {
+			return fmt.Sprintf("same pack count, less surplus (%d vs %d)", winner.SurplusItems, runnerUp.SurplusItems)
+		}
+
+		return "same pack count and surplus, larger packs"
+	case minCost:
+		if winner.Cost.Total != runnerUp.Cost.Total {
+			return fmt.Sprintf("lower cost (%d vs %d)", winner.Cost.Total, runnerUp.Cost.Total)
+		}
+
+		if winner.SurplusItems != runnerUp.SurplusItems {
+			return fmt.Sprintf("same cost, less surplus (%d vs %d)", winner.SurplusItems, runnerUp.SurplusItems)
+		}
+	}
+
+	if winner.SurplusItems != runnerUp.SurplusItems {
+		return fmt.Sprintf("less surplus (%d vs %d)", winner.SurplusItems, runnerUp.SurplusItems)
+	}
+
+	if winner.TotalPacks != runnerUp.TotalPacks {
+		return fmt.Sprintf("same surplus, fewer packs (%d vs %d)", winner.TotalPacks, runnerUp.TotalPacks)
+	}
+
+	return "same surplus and pack count, larger packs"
+}
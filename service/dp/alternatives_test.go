@@ -0,0 +1,164 @@
+package dp
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reparttask/service"
+	"testing"
+)
+
+func Test_CalculateAlternatives(t *testing.T) {
+	type testCaseInput struct {
+		calc          *Calc
+		packs         []int
+		orderQuantity int
+		k             int
+	}
+	type testCaseOutput struct {
+		want        []map[int]int
+		explanation string
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	packs := []int{250, 500, 1000, 2000, 5000}
+
+	tests := []testCase{
+		{
+			name: "test same surplus, fewer packs",
+			input: testCaseInput{
+				calc:          NewCalc(),
+				packs:         packs,
+				orderQuantity: 751,
+				k:             3,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{1000: 1}, {500: 2}, {500: 1, 250: 2}},
+				explanation: "same surplus, fewer packs (1 vs 2)",
+			},
+		},
+		{
+			name: "test same surplus before less surplus",
+			input: testCaseInput{
+				calc:          NewCalc(),
+				packs:         packs,
+				orderQuantity: 501,
+				k:             3,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{500: 1, 250: 1}, {250: 3}, {1000: 1}},
+				explanation: "same surplus, fewer packs (2 vs 3)",
+			},
+		},
+		{
+			name: "test less surplus",
+			input: testCaseInput{
+				calc:          NewCalc(),
+				packs:         []int{250, 500},
+				orderQuantity: 1,
+				k:             2,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{250: 1}, {500: 1}},
+				explanation: "less surplus (249 vs 499)",
+			},
+		},
+		{
+			name: "test min-packs objective",
+			input: testCaseInput{
+				calc:          NewMinPacksCalc(),
+				packs:         packs,
+				orderQuantity: 501,
+				k:             3,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{1000: 1}, {2000: 1}, {5000: 1}},
+				explanation: "same pack count, less surplus (499 vs 1499)",
+			},
+		},
+		{
+			name: "test more packings than one extra pack",
+			input: testCaseInput{
+				calc:          NewCalc(),
+				packs:         []int{5},
+				orderQuantity: 1,
+				k:             3,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{5: 1}, {5: 2}, {5: 3}},
+				explanation: "less surplus (4 vs 9)",
+			},
+		},
+		{
+			name: "test fewer packings available than requested",
+			input: testCaseInput{
+				calc:          NewMaxPacksCalc(2),
+				packs:         []int{250},
+				orderQuantity: 1,
+				k:             5,
+			},
+			expected: testCaseOutput{
+				want:        []map[int]int{{250: 1}, {250: 2}},
+				explanation: "less surplus (249 vs 499)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.input.calc.CalculateAlternatives(context.Background(), tt.input.packs, tt.input.orderQuantity, tt.input.k)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			maps := make([]map[int]int, len(got))
+			for i, res := range got {
+				maps[i] = res.Map()
+			}
+			assert.Equal(t, tt.expected.want, maps)
+
+			if len(got) > 1 {
+				assert.Equal(t, tt.expected.explanation, tt.input.calc.Explain(got[0], got[1]))
+			}
+		})
+	}
+}
+
+func Test_CalculateAlternativesMatchesBest(t *testing.T) {
+	calcs := []*Calc{NewCalc(), NewMinPacksCalc(), NewMinCostCalc(map[int]int64{23: 5, 31: 6, 53: 11}, 1), NewMaxPacksCalc(3)}
+	packs := []int{23, 31, 53}
+
+	for _, c := range calcs {
+		for target := 1; target <= 300; target++ {
+			best, err := c.CalculatePacks(context.Background(), packs, target)
+			if err != nil {
+				continue
+			}
+
+			got, err := c.CalculateAlternatives(context.Background(), packs, target, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !assert.Equal(t, best.Map(), got[0].Map(), "%s for %d", c.strategy, target) {
+				return
+			}
+		}
+	}
+}
+
+func Test_CalculateAlternativesLimits(t *testing.T) {
+	c := NewCalc()
+
+	_, err := c.CalculateAlternatives(context.Background(), []int{250}, 1, 0)
+	assert.Error(t, err)
+
+	_, err = c.CalculateAlternatives(context.Background(), []int{250}, 1, service.MaxAlternatives+1)
+	assert.Error(t, err)
+
+	_, err = c.CalculateAlternatives(context.Background(), []int{23, 31}, maxAlternativeUnits, 1)
+	assert.ErrorIs(t, err, service.ErrTooLarge)
+}
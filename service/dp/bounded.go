@@ -0,0 +1,173 @@
+package dp
+
+import (
+	"context"
+	"reparttask/service"
+	"reparttask/storage"
+)
+
+// item is a group of packs of the same size used by the bounded table,
+// multiple 0 means the size is unlimited.
+type item struct {
+	index    int
+	multiple int
+}
+
+// CalculateBoundedPacks works like CalculatePacks, but only the given stock is available
+// for the sizes present in stock, sizes missing from stock are unlimited.
+// It returns storage.ErrInsufficientStock when the stock can't cover the order.
+func (c *Calc) CalculateBoundedPacks(ctx context.Context, packs []int, stock map[int]int, target int) (service.Result, error) {
+	result := map[int]int{}
+
+	// sizes without any stock left can't be used at all.
+	var available []int
+	for _, size := range packs {
+		if n, ok := stock[size]; !ok || n > 0 {
+			available = append(available, size)
+		}
+	}
+
+	sizes := Normalize(available)
+	if target <= 0 {
+		return service.NewResult(c.strategy, target, result), nil
+	}
+
+	if len(sizes) == 0 {
+		return service.Result{}, storage.ErrInsufficientStock
+	}
+
+	g, units := Units(sizes)
+	goal, low, limit := c.span(target, g)
+
+	// same bound as the unlimited table, dropping a pack never exceeds the stock.
+	// When every size is limited, totals above the whole stock can't be reached either.
+	// huge orders are capped so that the bound can't overflow, they are rejected below.
+	bound := min(goal, maxTableUnits) + units[len(units)-1]
+	limited := 0
+	for i, size := range sizes {
+		n, ok := stock[size]
+		if !ok {
+			limited = -1
+			break
+		}
+
+		limited += n * units[i]
+	}
+
+	if limited >= 0 && limited+1 < bound {
+		if limited < low {
+			return service.Result{}, storage.ErrInsufficientStock
+		}
+
+		bound = limited + 1
+	}
+
+	if bound > maxTableUnits {
+		return service.Result{}, service.ErrTooLarge
+	}
+
+	// totals above the accepted surplus are left out of the table.
+	if limit >= 0 && limit+1 < bound {
+		bound = limit + 1
+	}
+
+	if bound <= low {
+		return service.Result{}, service.ErrNoSolution
+	}
+
+	// split every limited size into groups of 1, 2, 4, ... packs, so that any
+	// count up to the stock is a sum of distinct groups.
+	// example: stock 10 => groups 1, 2, 4, 3
+	var items []item
+	for i, size := range sizes {
+		n, ok := stock[size]
+		if !ok {
+			items = append(items, item{index: i})
+			continue
+		}
+
+		for m := 1; n > 0; m *= 2 {
+			if m > n {
+				m = n
+			}
+
+			items = append(items, item{index: i, multiple: m})
+			n -= m
+		}
+	}
+
+	if len(items) > maxTakenBits/bound {
+		return service.Result{}, service.ErrTooLarge
+	}
+
+	t := c.newTable(sizes, units, bound)
+	t.gcd, t.target = g, target
+
+	// taken[j] marks the totals whose best way uses item j.
+	taken := make([][]uint64, len(items))
+	for j, it := range items {
+		taken[j] = make([]uint64, bound/64+1)
+		u := units[it.index]
+
+		if it.multiple == 0 {
+			// unlimited sizes may be added again on top of themselves, so go upwards.
+			for total := u; total < bound; total++ {
+				if done(ctx, total) {
+					return service.Result{}, ctx.Err()
+				}
+
+				if t.relax(total-u, total, 1, t.weight(it.index, 1)) {
+					taken[j][total/64] |= 1 << (total % 64)
+				}
+			}
+			continue
+		}
+
+		// a group is used at most once, so go downwards.
+		step := u * it.multiple
+		for total := bound - 1; total >= step; total-- {
+			if done(ctx, total) {
+				return service.Result{}, ctx.Err()
+			}
+
+			if t.relax(total-step, total, uint32(it.multiple), t.weight(it.index, it.multiple)) {
+				taken[j][total/64] |= 1 << (total % 64)
+			}
+		}
+	}
+
+	total := t.best(low)
+	if c.objective == minSurplus && low < goal {
+		total = t.closest(low, goal, t.best(goal), t.accepted)
+	}
+
+	if total < 0 {
+		// when the goal can be covered at all, only the objective rejected it.
+		for covered := low; covered < bound; covered++ {
+			if t.count[covered] != unreachable {
+				return service.Result{}, service.ErrNoSolution
+			}
+		}
+
+		return service.Result{}, storage.ErrInsufficientStock
+	}
+
+	// walk back through the items in reverse order.
+	for j := len(items) - 1; j >= 0; j-- {
+		it := items[j]
+		u := units[it.index]
+		for total > 0 && taken[j][total/64]&(1<<(total%64)) != 0 {
+			if it.multiple == 0 {
+				result[sizes[it.index]]++
+				total -= u
+				continue
+			}
+
+			result[sizes[it.index]] += it.multiple
+			total -= u * it.multiple
+			break
+		}
+	}
+
+	return service.NewResult(c.strategy, target, result), nil
+}
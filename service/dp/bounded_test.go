@@ -0,0 +1,195 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"reparttask/service"
+	"reparttask/storage"
+	"testing"
+)
+
+func Test_CalculateBoundedPacks(t *testing.T) {
+	type testCaseInput struct {
+		packs         []int
+		stock         map[int]int
+		orderQuantity int
+	}
+	type testCaseOutput struct {
+		want map[int]int
+		err  error
+	}
+	type testCase struct {
+		name     string
+		calc     *Calc
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	tests := []testCase{
+		{
+			name: "test unlimited stock matches unbounded result",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500, 1000, 2000, 5000},
+				stock:         map[int]int{},
+				orderQuantity: 12001,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{5000: 2, 2000: 1, 250: 1},
+			},
+		},
+		{
+			// only one 5000 left, the remaining 7250 items use the fewest smaller packs.
+			name: "test limited largest pack",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500, 1000, 2000, 5000},
+				stock:         map[int]int{5000: 1},
+				orderQuantity: 12001,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{5000: 1, 2000: 3, 1000: 1, 250: 1},
+			},
+		},
+		{
+			name: "test size without stock is skipped",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500},
+				stock:         map[int]int{250: 0},
+				orderQuantity: 1,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{500: 1},
+			},
+		},
+		{
+			name: "test every size limited, stock is enough",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500},
+				stock:         map[int]int{250: 1, 500: 1},
+				orderQuantity: 600,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{250: 1, 500: 1},
+			},
+		},
+		{
+			name: "test every size limited, stock is not enough",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500},
+				stock:         map[int]int{250: 1, 500: 1},
+				orderQuantity: 1000,
+			},
+			expected: testCaseOutput{
+				err: storage.ErrInsufficientStock,
+			},
+		},
+		{
+			name: "test no stock at all",
+			calc: NewCalc(),
+			input: testCaseInput{
+				packs:         []int{250, 500},
+				stock:         map[int]int{250: 0, 500: 0},
+				orderQuantity: 1,
+			},
+			expected: testCaseOutput{
+				err: storage.ErrInsufficientStock,
+			},
+		},
+		{
+			name: "test stock covers the order, but not within the pack limit",
+			calc: NewMaxPacksCalc(1),
+			input: testCaseInput{
+				packs:         []int{250, 500},
+				stock:         map[int]int{500: 5},
+				orderQuantity: 600,
+			},
+			expected: testCaseOutput{
+				err: service.ErrNoSolution,
+			},
+		},
+		{
+			// 2 * 500 costs 24, but only one 500 is left so 500 + 2 * 250 costs 28 and 1000 costs 30.
+			name: "test min-cost with limited stock",
+			calc: NewMinCostCalc(map[int]int64{250: 8, 500: 12, 1000: 30}, 0),
+			input: testCaseInput{
+				packs:         []int{250, 500, 1000},
+				stock:         map[int]int{500: 1},
+				orderQuantity: 1000,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{500: 1, 250: 2},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := tt.calc.CalculateBoundedPacks(context.Background(), tt.input.packs, tt.input.stock, tt.input.orderQuantity)
+			if !errors.Is(err, tt.expected.err) {
+				t.Fatalf("got error %v, want %v", err, tt.expected.err)
+			}
+
+			got := res.Map()
+			if len(got) != len(tt.expected.want) {
+				t.Fatalf("got %v, want %v", got, tt.expected.want)
+			}
+
+			for k, v := range tt.expected.want {
+				if got[k] != v {
+					t.Errorf("for required pack: %d: got %v, want %v", k, got[k], v)
+					t.FailNow()
+				}
+			}
+		})
+	}
+}
+
+// Test_CalculateBoundedPacksBruteForce compares the bounded table with
+// an exhaustive search over every allowed pack count on small inputs.
+func Test_CalculateBoundedPacksBruteForce(t *testing.T) {
+	packs := []int{3, 7, 11}
+	c := NewCalc()
+
+	for a := 0; a <= 3; a++ {
+		for b := 0; b <= 3; b++ {
+			stock := map[int]int{3: a, 7: b}
+			for target := 1; target <= 60; target++ {
+				res, err := c.CalculateBoundedPacks(context.Background(), packs, stock, target)
+				if err != nil {
+					t.Fatalf("stock %v, target %d: %v", stock, target, err)
+				}
+
+				// 11 is unlimited, so its count never needs to exceed target / 11 + 1.
+				bestTotal, bestPacks := -1, 0
+				for x := 0; x <= a; x++ {
+					for y := 0; y <= b; y++ {
+						for z := 0; z <= target/11+1; z++ {
+							total := 3*x + 7*y + 11*z
+							if total < target {
+								continue
+							}
+
+							if bestTotal < 0 || total < bestTotal || (total == bestTotal && x+y+z < bestPacks) {
+								bestTotal, bestPacks = total, x+y+z
+							}
+						}
+					}
+				}
+
+				got := res.Map()
+				if got[3] > a || got[7] > b {
+					t.Fatalf("stock %v, target %d: %v exceeds the stock", stock, target, got)
+				}
+
+				if res.TotalItems != bestTotal || res.TotalPacks != bestPacks {
+					t.Fatalf("stock %v, target %d: got %d items in %d packs, want %d items in %d packs",
+						stock, target, res.TotalItems, res.TotalPacks, bestTotal, bestPacks)
+				}
+			}
+		}
+	}
+}
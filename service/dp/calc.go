@@ -0,0 +1,418 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reparttask/service"
+	"sort"
+)
+
+// Strategy is the name reported in results computed by the default Calc.
+const Strategy = "dp"
+
+// unreachable marks a total that cannot be built from the available packs.
+const unreachable = math.MaxUint32
+
+// checkEvery is how many totals are processed between two context checks.
+const checkEvery = 1 << 14
+
+// objective decides which reachable total wins.
+type objective int
+
+const (
+	// minSurplus picks the smallest total >= target, then the fewest packs.
+	minSurplus objective = iota
+	// minPacks picks the fewest packs, then the smallest total.
+	minPacks
+	// minCost picks the cheapest packing including surplus items, then the smallest total,
+	// then the fewest packs.
+	// For a given total the table already keeps the fewest packs among the cheapest ones.
+	minCost
+)
+
+type Calc struct {
+	strategy    string
+	objective   objective
+	costs       map[int]int64
+	surplusCost int64
+	maxPacks    int
+	tolerance   service.Tolerance
+}
+
+// NewCalc returns a calculator that minimises surplus items, then the number of packs.
+func NewCalc() *Calc {
+	return &Calc{strategy: Strategy, objective: minSurplus}
+}
+
+// CalculatePacks function is used to find the best fit for the target value.
+// It builds a reachability table where every entry holds the best way to reach
+// that total, then picks the winning total >= target according to the objective.
+func (c *Calc) CalculatePacks(ctx context.Context, packs []int, target int) (service.Result, error) {
+	result := map[int]int{}
+
+	sizes := Normalize(packs)
+	if target <= 0 || len(sizes) == 0 {
+		return service.NewResult(c.strategy, target, result), nil
+	}
+
+	// every reachable total is a multiple of the gcd, so we can work in gcd units
+	// example: packs 250, 500, 1000 => units 1, 2, 4
+	g, units := Units(sizes)
+	goal, low, limit := c.span(target, g)
+
+	// for huge orders the bulk is filled with the largest pack and only the residual is searched.
+	largest := units[len(units)-1]
+	extra := 0
+	capacity := maxTableUnits
+	if c.objective == minSurplus && c.maxPacks == 0 {
+		low, extra = reduce(units, low)
+		goal -= extra * largest
+		if limit >= 0 {
+			limit -= extra * largest
+		}
+		capacity = maxUnits
+	}
+
+	// any packing above goal + largest pack contains a pack that can be dropped
+	// while still covering the goal, with fewer packs, lower cost and less surplus,
+	// so the answer is always inside this bound.
+	if goal > capacity-largest {
+		return service.Result{}, service.ErrTooLarge
+	}
+	bound := goal + largest
+	if limit >= 0 && limit+1 < bound {
+		bound = limit + 1
+	}
+
+	if bound <= low {
+		return service.Result{}, service.ErrNoSolution
+	}
+
+	// a cancelled request may not reach the first context check of a small table.
+	if err := ctx.Err(); err != nil {
+		return service.Result{}, err
+	}
+
+	// the table only sees the residual of a reduced order.
+	t := c.newTable(sizes, units, bound)
+	t.gcd, t.target = g, target-extra*largest*g
+
+	accept := c.accept(t)
+	from := goal
+	if accept == nil {
+		from = low
+	}
+
+	total, err := t.fill(ctx, from, accept)
+	if err != nil {
+		return service.Result{}, err
+	}
+
+	if accept != nil && low < goal {
+		total = t.closest(low, goal, total, accept)
+	}
+
+	if total < 0 {
+		return service.Result{}, service.ErrNoSolution
+	}
+
+	// the packed items must still fit in an int.
+	if extra > (math.MaxInt/g-total)/largest {
+		return service.Result{}, service.ErrTooLarge
+	}
+
+	// walk back through the table, preferring larger packs on ties.
+	for rest := total; rest > 0; {
+		i := t.previous(rest)
+		result[sizes[i]]++
+		rest -= units[i]
+	}
+
+	if extra > 0 {
+		result[sizes[len(sizes)-1]] += extra
+	}
+
+	return service.NewResult(c.strategy, target, result), nil
+}
+
+// accept returns the rule used to pick the winning total while the table is filled.
+func (c *Calc) accept(t *table) func(total int) bool {
+	if c.objective == minSurplus {
+		// the first reachable total that covers the goal is the best one,
+		// and its pack count is already final.
+		return t.accepted
+	}
+
+	// the other objectives need every total up to the bound.
+	return nil
+}
+
+// span returns, in gcd units, the goal covering target, the lowest total accepted
+// when the order may ship short and the highest accepted total, -1 when unlimited.
+func (c *Calc) span(target int, g int) (int, int, int) {
+	// written without target + g - 1 so that it can't overflow for huge orders.
+	goal := target/g + min(target%g, 1)
+
+	low := goal
+	if c.tolerance.MaxShort > 0 {
+		short := max(target-c.tolerance.MaxShort, 0)
+		low = short/g + min(short%g, 1)
+	}
+
+	limit := -1
+	if c.tolerance.MaxSurplus != nil && *c.tolerance.MaxSurplus <= math.MaxInt-target {
+		limit = (target + *c.tolerance.MaxSurplus) / g
+	}
+
+	return goal, low, limit
+}
+
+// WithTolerance returns a copy of the calculator that accepts packings
+// within the tolerance around the ordered quantity.
+func (c *Calc) WithTolerance(tolerance service.Tolerance) (service.Calculator, error) {
+	if tolerance.MaxShort < 0 || (tolerance.MaxSurplus != nil && *tolerance.MaxSurplus < 0) {
+		return nil, errors.New("tolerance must not be negative")
+	}
+
+	tolerant := *c
+	tolerant.tolerance = tolerance
+	return &tolerant, nil
+}
+
+// table holds the best way to reach every total, in gcd units.
+type table struct {
+	units   []int
+	weights []int64
+	count   []uint32
+	// cost is only filled for the cost objective, otherwise the pack count is the weight.
+	cost []int64
+	// gcd & target convert a total back to items when pricing the surplus.
+	gcd         int
+	target      int
+	surplusCost int64
+	objective   objective
+	maxPacks    int
+}
+
+func (c *Calc) newTable(sizes []int, units []int, bound int) *table {
+	t := &table{
+		units:       units,
+		count:       make([]uint32, bound),
+		surplusCost: c.surplusCost,
+		objective:   c.objective,
+		maxPacks:    c.maxPacks,
+	}
+
+	for i := 1; i < bound; i++ {
+		t.count[i] = unreachable
+	}
+
+	if c.objective == minCost {
+		t.cost = make([]int64, bound)
+		t.weights = make([]int64, len(sizes))
+		for i, size := range sizes {
+			// the results are priced the same way.
+			t.weights[i] = service.UnitCost(c.costs, size)
+		}
+	}
+
+	return t
+}
+
+// fill computes the table in ascending order of totals.
+// When accept is set, filling stops at the first accepted total >= goal,
+// otherwise the whole table is filled and the best total is selected.
+// It returns -1 when no total satisfies the objective.
+func (t *table) fill(ctx context.Context, goal int, accept func(total int) bool) (int, error) {
+	for total := 1; total < len(t.count); total++ {
+		if done(ctx, total) {
+			return -1, ctx.Err()
+		}
+
+		for i, u := range t.units {
+			if u > total {
+				break
+			}
+
+			t.relax(total-u, total, 1, t.weight(i, 1))
+		}
+
+		if accept != nil && total >= goal && accept(total) {
+			return total, nil
+		}
+	}
+
+	if accept != nil {
+		return -1, nil
+	}
+
+	return t.best(goal), nil
+}
+
+// weight returns the cost of n packs of size i, only used by the cost objective.
+func (t *table) weight(i int, n int) int64 {
+	if t.cost == nil {
+		return 0
+	}
+
+	return t.weights[i] * int64(n)
+}
+
+// relax stores the way to reach to by adding packs (with the given cost)
+// on top of total from, when it beats the current one. It reports whether it did.
+func (t *table) relax(from int, to int, packs uint32, cost int64) bool {
+	if t.count[from] == unreachable {
+		return false
+	}
+
+	if t.count[to] != unreachable {
+		if t.cost != nil && t.cost[from]+cost != t.cost[to] {
+			if t.cost[from]+cost > t.cost[to] {
+				return false
+			}
+		} else if t.count[from]+packs >= t.count[to] {
+			return false
+		}
+	}
+
+	t.count[to] = t.count[from] + packs
+	if t.cost != nil {
+		t.cost[to] = t.cost[from] + cost
+	}
+
+	return true
+}
+
+// best selects the winning total >= goal once the table is complete.
+func (t *table) best(goal int) int {
+	best := -1
+	for total := goal; total < len(t.count); total++ {
+		if t.count[total] == unreachable {
+			continue
+		}
+
+		if t.maxPacks > 0 && int(t.count[total]) > t.maxPacks {
+			continue
+		}
+
+		if best < 0 {
+			best = total
+			continue
+		}
+
+		// totals are visited in ascending order, so on ties the smaller total is kept.
+		switch t.objective {
+		case minPacks:
+			if t.count[total] < t.count[best] {
+				best = total
+			}
+		case minCost:
+			if t.cost[total]+t.surplus(total) < t.cost[best]+t.surplus(best) {
+				best = total
+			}
+		}
+	}
+
+	return best
+}
+
+// surplus returns the cost of the items above the target for a total.
+func (t *table) surplus(total int) int64 {
+	return int64(max(total*t.gcd-t.target, 0)) * t.surplusCost
+}
+
+// distance returns how many items a total is away from the target.
+func (t *table) distance(total int) int {
+	if d := total*t.gcd - t.target; d > 0 {
+		return d
+	}
+
+	return t.target - total*t.gcd
+}
+
+// accepted reports whether total is reachable within the pack limit.
+func (t *table) accepted(total int) bool {
+	return t.count[total] != unreachable && (t.maxPacks == 0 || int(t.count[total]) <= t.maxPacks)
+}
+
+// closest returns whichever of up, the winning total >= goal, and the largest accepted
+// total in [low, goal) is closer to the target, preferring up on ties so that the
+// order is covered. It returns -1 when neither exists.
+func (t *table) closest(low int, goal int, up int, accept func(total int) bool) int {
+	down := -1
+	for total := min(goal, len(t.count)) - 1; total >= low; total-- {
+		if accept(total) {
+			down = total
+			break
+		}
+	}
+
+	if down < 0 || (up >= 0 && t.distance(up) <= t.distance(down)) {
+		return up
+	}
+
+	return down
+}
+
+// previous returns the index of the last pack used to reach total, preferring larger packs.
+func (t *table) previous(total int) int {
+	for i := len(t.units) - 1; i >= 0; i-- {
+		u := t.units[i]
+		if u > total || t.count[total-u] == unreachable || t.count[total-u]+1 != t.count[total] {
+			continue
+		}
+
+		if t.cost != nil && t.cost[total-u]+t.weights[i] != t.cost[total] {
+			continue
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+// done reports whether ctx is done, it is only checked every checkEvery iterations
+// so that the tight loops don't slow down.
+func done(ctx context.Context, iteration int) bool {
+	return iteration%checkEvery == 0 && ctx.Err() != nil
+}
+
+// Normalize returns a sorted copy of the positive, distinct pack sizes.
+func Normalize(packs []int) []int {
+	seen := map[int]bool{}
+	var sizes []int
+	for _, size := range packs {
+		if size > 0 && !seen[size] {
+			seen[size] = true
+			sizes = append(sizes, size)
+		}
+	}
+
+	sort.Ints(sizes)
+	return sizes
+}
+
+// Units returns the gcd of the sizes and every size in gcd units.
+func Units(sizes []int) (int, []int) {
+	g := sizes[0]
+	for _, size := range sizes[1:] {
+		g = gcd(g, size)
+	}
+
+	units := make([]int, len(sizes))
+	for i, size := range sizes {
+		units[i] = size / g
+	}
+
+	return g, units
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+
+	return a
+}
@@ -0,0 +1,242 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func Test_CalculatePacks(t *testing.T) {
+	type testCaseInput struct {
+		input         []int
+		orderQuantity int
+	}
+	type testCaseOutput struct {
+		want map[int]int
+	}
+	type testCase struct {
+		name     string
+		input    testCaseInput
+		expected testCaseOutput
+	}
+
+	tests := []testCase{
+		{
+			name: "test for 1 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 1,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{250: 1},
+			},
+		},
+		{
+			name: "test for 250 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 250,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{250: 1},
+			},
+		},
+		{
+			name: "test for 251 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 251,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{500: 1},
+			},
+		},
+		{
+			name: "test for 501 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 501,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{250: 1, 500: 1},
+			},
+		},
+		{
+			name: "test for 12001 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 12001,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{5000: 2, 2000: 1, 250: 1},
+			},
+		},
+		{
+			name: "test for 751 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 751,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{1000: 1},
+			},
+		},
+		{
+			name: "test for 1251 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 1251,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{1000: 1, 500: 1},
+			},
+		},
+		{
+			name: "test for negative order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: -1,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{},
+			},
+		},
+		{
+			name: "test for different input pack sizes",
+			input: testCaseInput{
+				input:         []int{23, 37, 45, 100, 500},
+				orderQuantity: 46,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{23: 2},
+			},
+		},
+		{
+			name: "test 2 for different input pack sizes",
+			input: testCaseInput{
+				input:         []int{23, 70, 73, 100, 500},
+				orderQuantity: 69,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{23: 3},
+			},
+		},
+		{
+			name: "test for 10^7 order size",
+			input: testCaseInput{
+				input:         []int{250, 2000, 500, 1000, 5000},
+				orderQuantity: 10_000_001,
+			},
+			expected: testCaseOutput{
+				want: map[int]int{5000: 2000, 250: 1},
+			},
+		},
+		{
+			name: "test for 10^7 order size with co-prime packs",
+			input: testCaseInput{
+				input:         []int{23, 31, 53},
+				orderQuantity: 10_000_000,
+			},
+			// (188674 * 53) + (6 * 31) + (4 * 23) = 10000000 ===> 0 items left
+			expected: testCaseOutput{
+				want: map[int]int{53: 188674, 31: 6, 23: 4},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := NewCalc()
+			res, err := c.CalculatePacks(context.Background(), tt.input.input, tt.input.orderQuantity)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := res.Map()
+
+			if len(got) != len(tt.expected.want) {
+				t.Fatalf("got %v, want %v", got, tt.expected.want)
+			}
+
+			for k, v := range tt.expected.want {
+				if got[k] != v {
+					t.Errorf("for required pack: %d: got %v, want %v", k, got[k], v)
+					t.FailNow()
+				}
+			}
+		})
+	}
+}
+
+// durationPacks are the pack sets timed for 10^7 items.
+var durationPacks = [][]int{{23, 31, 53}, {250, 500, 1000, 2000, 5000}}
+
+func Test_CalculatePacksDuration(t *testing.T) {
+	c := NewCalc()
+
+	// the answers take milliseconds, see Benchmark_CalculatePacks,
+	// the bound only catches a regression to a full table and leaves room for slow or instrumented runs.
+	for _, packs := range durationPacks {
+		start := time.Now()
+		if _, err := c.CalculatePacks(context.Background(), packs, 10_000_000); err != nil {
+			t.Fatalf("calculation for 10^7 items with packs %v: %v", packs, err)
+		}
+
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("calculation for 10^7 items with packs %v took %v", packs, elapsed)
+		}
+	}
+}
+
+// Benchmark_CalculatePacks times 10^7 items, it runs in well under a millisecond per calculation.
+func Benchmark_CalculatePacks(b *testing.B) {
+	c := NewCalc()
+	for _, packs := range durationPacks {
+		b.Run(fmt.Sprint(packs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := c.CalculatePacks(context.Background(), packs, 10_000_000); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_CalculatePacksInputUnchanged(t *testing.T) {
+	packs := []int{5000, 250, 2000, 500, 1000}
+	want := []int{5000, 250, 2000, 500, 1000}
+
+	c := NewCalc()
+	c.CalculatePacks(context.Background(), packs, 12001)
+
+	for i := range want {
+		if packs[i] != want[i] {
+			t.Fatalf("input packs were modified: got %v, want %v", packs, want)
+		}
+	}
+}
+
+func Test_CalculatePacksCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	c := NewCalc()
+	_, err := c.CalculatePacks(ctx, []int{23, 31, 53}, 10_000_000)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	_, err = c.CalculateBoundedPacks(ctx, []int{23, 31, 53}, map[int]int{53: 10}, 1_000_000)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+
+	_, err = c.CalculateAlternatives(ctx, []int{23, 31, 53}, 100_000, 3)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,224 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"reparttask/service"
+	"testing"
+)
+
+// tolerant returns a copy of c with the tolerance applied.
+func tolerant(t *testing.T, c *Calc, tolerance service.Tolerance) *Calc {
+	calc, err := c.WithTolerance(tolerance)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return calc.(*Calc)
+}
+
+func surplus(n int) *int {
+	return &n
+}
+
+func Test_Exact(t *testing.T) {
+	c := tolerant(t, NewCalc(), service.Tolerance{MaxSurplus: surplus(0)})
+
+	runObjectiveTests(t, c, []objectiveTestCase{
+		{
+			name:          "test for 500 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 500,
+			want:          map[int]int{500: 1},
+		},
+		{
+			name:          "test for 12000 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12000,
+			want:          map[int]int{5000: 2, 2000: 1},
+		},
+		{
+			name:          "test for 501 order size, no exact packing",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 501,
+			err:           service.ErrNoSolution,
+		},
+		{
+			name:          "test for 100 order size with co-prime packs",
+			packs:         []int{23, 31, 53},
+			orderQuantity: 100,
+			want:          map[int]int{23: 3, 31: 1},
+		},
+		{
+			name:          "test for 2000000000 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 2_000_000_000,
+			want:          map[int]int{5000: 400_000},
+		},
+		{
+			name:          "test for 2000000001 order size, no exact packing",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 2_000_000_001,
+			err:           service.ErrNoSolution,
+		},
+	})
+}
+
+func Test_ExactMinPacks(t *testing.T) {
+	c := tolerant(t, NewMinPacksCalc(), service.Tolerance{MaxSurplus: surplus(0)})
+
+	runObjectiveTests(t, c, []objectiveTestCase{
+		{
+			// a single 5000 pack would be fewer packs, but leaves 250 items over.
+			name:          "test for 4750 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 4750,
+			want:          map[int]int{2000: 2, 500: 1, 250: 1},
+		},
+	})
+}
+
+func Test_AllowShort(t *testing.T) {
+	runObjectiveTests(t, tolerant(t, NewCalc(), service.Tolerance{MaxShort: 10}), []objectiveTestCase{
+		{
+			name:          "test for 251 order size, 1 item short",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 251,
+			want:          map[int]int{250: 1},
+		},
+		{
+			name:          "test for 261 order size, too short",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 261,
+			want:          map[int]int{500: 1},
+		},
+		{
+			name:          "test for 2000000001 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 2_000_000_001,
+			want:          map[int]int{5000: 400_000},
+		},
+	})
+
+	runObjectiveTests(t, tolerant(t, NewCalc(), service.Tolerance{MaxShort: 200}), []objectiveTestCase{
+		{
+			// 125 short or 125 over, the order is covered on ties.
+			name:          "test for 375 order size, tie",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 375,
+			want:          map[int]int{500: 1},
+		},
+		{
+			// shipping nothing is 100 short, a 250 pack is 150 over.
+			name:          "test for 100 order size, nothing shipped",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 100,
+			want:          map[int]int{},
+		},
+	})
+}
+
+func Test_AllowShortResult(t *testing.T) {
+	c := tolerant(t, NewCalc(), service.Tolerance{MaxShort: 10})
+
+	res, err := c.CalculatePacks(context.Background(), []int{250, 500}, 255)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.TotalItems != 250 || res.ShortItems != 5 || res.SurplusItems != 0 {
+		t.Fatalf("got %d items, %d short, %d over, want 250 items, 5 short, 0 over",
+			res.TotalItems, res.ShortItems, res.SurplusItems)
+	}
+}
+
+func Test_MaxSurplus(t *testing.T) {
+	runObjectiveTests(t, tolerant(t, NewCalc(), service.Tolerance{MaxSurplus: surplus(100)}), []objectiveTestCase{
+		{
+			name:          "test for 12001 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12001,
+			err:           service.ErrNoSolution,
+		},
+		{
+			name:          "test for 12200 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12200,
+			want:          map[int]int{5000: 2, 2000: 1, 250: 1},
+		},
+	})
+
+	// a single 1000 pack leaves 499 items over, 250 + 500 leave 249.
+	runObjectiveTests(t, tolerant(t, NewMinPacksCalc(), service.Tolerance{MaxSurplus: surplus(300)}), []objectiveTestCase{
+		{
+			name:          "test for 501 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 501,
+			want:          map[int]int{500: 1, 250: 1},
+		},
+	})
+}
+
+func Test_ToleranceBounded(t *testing.T) {
+	ctx := context.Background()
+	packs := []int{250, 500, 1000}
+
+	c := tolerant(t, NewCalc(), service.Tolerance{MaxSurplus: surplus(0)})
+	res, err := c.CalculateBoundedPacks(ctx, packs, map[int]int{250: 1, 500: 1}, 750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := res.Map(); len(got) != 2 || got[500] != 1 || got[250] != 1 {
+		t.Fatalf("got %v, want map[250:1 500:1]", got)
+	}
+
+	_, err = c.CalculateBoundedPacks(ctx, packs, map[int]int{250: 0}, 750)
+	if !errors.Is(err, service.ErrNoSolution) {
+		t.Fatalf("got error %v, want %v", err, service.ErrNoSolution)
+	}
+
+	c = tolerant(t, NewCalc(), service.Tolerance{MaxShort: 10})
+	res, err = c.CalculateBoundedPacks(ctx, packs, map[int]int{250: 0}, 510)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := res.Map(); len(got) != 1 || got[500] != 1 {
+		t.Fatalf("got %v, want map[500:1]", got)
+	}
+}
+
+func Test_ToleranceAlternatives(t *testing.T) {
+	c := tolerant(t, NewCalc(), service.Tolerance{MaxShort: 10})
+
+	results, err := c.CalculateAlternatives(context.Background(), []int{250, 500}, 251, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].TotalItems != 250 || results[1].TotalItems != 500 {
+		t.Fatalf("got %+v, want 250 items then 500 items", results)
+	}
+}
+
+func Test_WithTolerance(t *testing.T) {
+	c := NewCalc()
+
+	_, err := c.WithTolerance(service.Tolerance{MaxShort: -1})
+	if err == nil {
+		t.Fatal("expected an error for a negative shortfall")
+	}
+
+	_, err = c.WithTolerance(service.Tolerance{MaxSurplus: surplus(-1)})
+	if err == nil {
+		t.Fatal("expected an error for a negative surplus")
+	}
+
+	// the original calculator is left unchanged.
+	tolerant(t, c, service.Tolerance{MaxSurplus: surplus(0)})
+	res, err := c.CalculatePacks(context.Background(), []int{250}, 1)
+	if err != nil || res.TotalItems != 250 {
+		t.Fatalf("got %+v, %v, want 250 items", res, err)
+	}
+}
@@ -0,0 +1,70 @@
+package dp
+
+import (
+	"errors"
+	"reparttask/service"
+)
+
+// Objective names callers can pick through the registry.
+const (
+	ObjectiveMinSurplus = "min-surplus"
+	ObjectiveMinPacks   = "min-packs"
+	ObjectiveMinCost    = "min-cost"
+	ObjectiveMaxPacks   = "max-packs"
+)
+
+// NewMinSurplusCalc returns a calculator that minimises surplus items, then the number of packs.
+func NewMinSurplusCalc() *Calc {
+	return &Calc{strategy: ObjectiveMinSurplus, objective: minSurplus}
+}
+
+// NewMinPacksCalc returns a calculator that minimises the number of packs, then surplus items.
+func NewMinPacksCalc() *Calc {
+	return &Calc{strategy: ObjectiveMinPacks, objective: minPacks}
+}
+
+// NewMinCostCalc returns a calculator that minimises the total packaging cost,
+// then surplus items. Every surplus item adds surplusCost to the total and
+// pack sizes missing from costs cost one unit each.
+func NewMinCostCalc(costs map[int]int64, surplusCost int64) *Calc {
+	return &Calc{strategy: ObjectiveMinCost, objective: minCost, costs: costs, surplusCost: surplusCost}
+}
+
+// NewMaxPacksCalc returns a calculator that minimises surplus items
+// using at most limit packs.
+func NewMaxPacksCalc(limit int) *Calc {
+	return &Calc{strategy: ObjectiveMaxPacks, objective: minSurplus, maxPacks: limit}
+}
+
+// Register adds every objective provided by this package to the registry.
+func Register(r *service.Registry) {
+	r.Register(ObjectiveMinSurplus, func(p service.Params) (service.Calculator, error) {
+		return NewMinSurplusCalc(), nil
+	})
+
+	r.Register(ObjectiveMinPacks, func(p service.Params) (service.Calculator, error) {
+		return NewMinPacksCalc(), nil
+	})
+
+	r.Register(ObjectiveMinCost, func(p service.Params) (service.Calculator, error) {
+		for _, cost := range p.Costs {
+			if cost < 0 {
+				return nil, errors.New("pack cost must not be negative")
+			}
+		}
+
+		if p.SurplusCost < 0 {
+			return nil, errors.New("surplus cost must not be negative")
+		}
+
+		return NewMinCostCalc(p.Costs, p.SurplusCost), nil
+	})
+
+	r.Register(ObjectiveMaxPacks, func(p service.Params) (service.Calculator, error) {
+		if p.MaxPacks <= 0 {
+			return nil, errors.New("max packs must be greater than zero")
+		}
+
+		return NewMaxPacksCalc(p.MaxPacks), nil
+	})
+}
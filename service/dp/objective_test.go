@@ -0,0 +1,250 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"reparttask/service"
+	"testing"
+)
+
+type objectiveTestCase struct {
+	name          string
+	packs         []int
+	orderQuantity int
+	want          map[int]int
+	err           error
+}
+
+func runObjectiveTests(t *testing.T, c *Calc, tests []objectiveTestCase) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := c.CalculatePacks(context.Background(), tt.packs, tt.orderQuantity)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+
+			got := res.Map()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("for required pack: %d: got %v, want %v", k, got[k], v)
+					t.FailNow()
+				}
+			}
+		})
+	}
+}
+
+func Test_MinSurplus(t *testing.T) {
+	runObjectiveTests(t, NewMinSurplusCalc(), []objectiveTestCase{
+		{
+			name:          "test for 12001 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12001,
+			want:          map[int]int{5000: 2, 2000: 1, 250: 1},
+		},
+		{
+			// 4 packs cover 100 exactly, 2 packs would leave 6 items over.
+			name:          "test exact total preferred over fewer packs",
+			packs:         []int{23, 31, 53},
+			orderQuantity: 100,
+			want:          map[int]int{23: 3, 31: 1},
+		},
+	})
+}
+
+func Test_MinPacks(t *testing.T) {
+	runObjectiveTests(t, NewMinPacksCalc(), []objectiveTestCase{
+		{
+			name:          "test for 1 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 1,
+			want:          map[int]int{250: 1},
+		},
+		{
+			// 2 packs can't reach 12001, with 3 packs only 3 * 5000 is enough.
+			name:          "test for 12001 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12001,
+			want:          map[int]int{5000: 3},
+		},
+		{
+			// a single 1000 pack beats 250 + 500 even with more surplus.
+			name:          "test for 501 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 501,
+			want:          map[int]int{1000: 1},
+		},
+		{
+			name:          "test fewer packs preferred over exact total",
+			packs:         []int{23, 31, 53},
+			orderQuantity: 100,
+			want:          map[int]int{53: 2},
+		},
+	})
+}
+
+func Test_MinCost(t *testing.T) {
+	costs := map[int]int64{250: 10, 500: 12, 1000: 30, 2000: 50, 5000: 100}
+
+	runObjectiveTests(t, NewMinCostCalc(costs, 0), []objectiveTestCase{
+		{
+			// 2 * 500 costs 24, 1 * 1000 costs 30.
+			name:          "test for 1000 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 1000,
+			want:          map[int]int{500: 2},
+		},
+		{
+			// 250 + 500 costs 22, 2 * 500 costs 24, 1 * 1000 costs 30.
+			name:          "test for 501 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 501,
+			want:          map[int]int{250: 1, 500: 1},
+		},
+		{
+			// (2 * 5000) + (4 * 500) + 250 costs 258, (2 * 5000) + 2000 + 250 costs 260.
+			name:          "test for 12001 order size",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12001,
+			want:          map[int]int{5000: 2, 500: 4, 250: 1},
+		},
+	})
+
+	runObjectiveTests(t, NewMinCostCalc(costs, 1), []objectiveTestCase{
+		{
+			// 1000 costs 30 + 499 surplus items, 250 + 500 costs 22 + 249 surplus items.
+			name:          "test for 501 order size with priced surplus",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 501,
+			want:          map[int]int{250: 1, 500: 1},
+		},
+	})
+
+	runObjectiveTests(t, NewMinCostCalc(map[int]int64{23: 1, 53: 100}, 10), []objectiveTestCase{
+		{
+			// 53 costs 100 with no surplus, 3 * 23 costs 3 + (16 * 10) for the surplus.
+			name:          "test surplus cost outweighs cheaper packs",
+			packs:         []int{23, 53},
+			orderQuantity: 53,
+			want:          map[int]int{53: 1},
+		},
+		{
+			// 3 * 23 costs 3 + (1 * 10) for the surplus, 53 + 23 costs 101 + (8 * 10).
+			name:          "test cheaper packs with small surplus",
+			packs:         []int{23, 53},
+			orderQuantity: 68,
+			want:          map[int]int{23: 3},
+		},
+	})
+
+	runObjectiveTests(t, NewMinCostCalc(nil, 0), []objectiveTestCase{
+		{
+			name:          "test unit cost per pack without costs",
+			packs:         []int{250, 500, 1000, 2000, 5000},
+			orderQuantity: 12001,
+			want:          map[int]int{5000: 3},
+		},
+	})
+}
+
+func Test_MaxPacks(t *testing.T) {
+	tests := []struct {
+		limit int
+		cases []objectiveTestCase
+	}{
+		{
+			limit: 4,
+			cases: []objectiveTestCase{
+				{
+					name:          "test limit not reached",
+					packs:         []int{250, 500, 1000, 2000, 5000},
+					orderQuantity: 12001,
+					want:          map[int]int{5000: 2, 2000: 1, 250: 1},
+				},
+			},
+		},
+		{
+			limit: 3,
+			cases: []objectiveTestCase{
+				{
+					name:          "test limit forces more surplus",
+					packs:         []int{250, 500, 1000, 2000, 5000},
+					orderQuantity: 12001,
+					want:          map[int]int{5000: 3},
+				},
+				{
+					// 100 needs 4 packs, 2 * 53 = 106 is the closest total within the limit.
+					name:          "test limit forces surplus with co-prime sizes",
+					packs:         []int{23, 31, 53},
+					orderQuantity: 100,
+					want:          map[int]int{53: 2},
+				},
+			},
+		},
+		{
+			limit: 2,
+			cases: []objectiveTestCase{
+				{
+					name:          "test no solution within limit",
+					packs:         []int{250, 500, 1000, 2000, 5000},
+					orderQuantity: 12001,
+					err:           service.ErrNoSolution,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		runObjectiveTests(t, NewMaxPacksCalc(tt.limit), tt.cases)
+	}
+}
+
+func Test_Register(t *testing.T) {
+	r := service.NewRegistry()
+	Register(r)
+
+	tests := []struct {
+		name     string
+		params   service.Params
+		strategy string
+		wantErr  bool
+	}{
+		{name: ObjectiveMinSurplus, strategy: ObjectiveMinSurplus},
+		{name: ObjectiveMinPacks, strategy: ObjectiveMinPacks},
+		{name: ObjectiveMinCost, strategy: ObjectiveMinCost},
+		{name: ObjectiveMinCost, params: service.Params{Costs: map[int]int64{250: -1}}, wantErr: true},
+		{name: ObjectiveMinCost, params: service.Params{SurplusCost: -1}, wantErr: true},
+		{name: ObjectiveMaxPacks, params: service.Params{MaxPacks: 2}, strategy: ObjectiveMaxPacks},
+		{name: ObjectiveMaxPacks, wantErr: true},
+		{name: "unknown", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := r.New(tt.name, tt.params)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := c.CalculatePacks(context.Background(), []int{250, 500}, 251)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.Strategy != tt.strategy {
+				t.Errorf("got strategy %s, want %s", res.Strategy, tt.strategy)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package dp
+
+import "math"
+
+// maxUnits is the largest table a single calculation may allocate, in gcd units.
+const maxUnits = 1 << 26
+
+// maxTableUnits is the largest table of the calculations that can't be reduced, the other
+// objectives and the stock keep every total up to the bound, with its cost.
+const maxTableUnits = 1 << 21
+
+// maxTakenBits caps the bitmaps of a calculation with stock, one bit per total and stock group.
+const maxTakenBits = 1 << 27
+
+// Threshold returns the total, in units, above which every optimal packing holds
+// a pack of the largest unit, and whether it fits in an int.
+//
+// Let L be the largest unit and u the second largest one. An optimal packing never
+// holds L or more packs smaller than L: their prefix sums would repeat modulo L,
+// so the packs in between sum to m * L using more than m packs, which m packs of L
+// replace with fewer packs. Those packs sum to at most (L - 1) * u, so every
+// optimal packing of a larger total holds a pack of L, and removing it leaves an
+// optimal packing of total - L. The same threshold is above the largest total that
+// can't be reached, (u1 - 1) * (L - 1) - 1 for the smallest unit u1, so every goal
+// above it is reached without surplus and its answer is answer(goal - L) + one L.
+func Threshold(units []int) (int, bool) {
+	n := len(units)
+	if n < 2 {
+		return 0, true
+	}
+
+	largest := units[n-1]
+	if units[n-2] > math.MaxInt/largest {
+		return 0, false
+	}
+
+	return (largest - 1) * units[n-2], true
+}
+
+// reduce splits goal into a residual goal and a number of largest packs, so that
+// the best packing of goal is the best packing of the residual plus those packs.
+// It only holds for the surplus objective without a pack limit, see Threshold.
+// The table walk prefers larger packs on ties, so it picks the same packing.
+func reduce(units []int, goal int) (int, int) {
+	largest := units[len(units)-1]
+
+	threshold, ok := Threshold(units)
+	if !ok || goal <= threshold+largest {
+		return goal, 0
+	}
+
+	// leave a residual in (threshold, threshold + largest].
+	packs := (goal - threshold - 1) / largest
+	return goal - packs*largest, packs
+}
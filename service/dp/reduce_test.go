@@ -0,0 +1,217 @@
+package dp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"reparttask/service"
+	"testing"
+)
+
+// Test_CalculatePacksReducedMatchesTable compares the reduced calculation with
+// the full table on random pack sets, with or without a shortfall. A pack limit
+// no order can reach disables the reduction without changing the answer.
+func Test_CalculatePacksReducedMatchesTable(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+
+	for i := 0; i < 200; i++ {
+		packs := make([]int, rnd.Intn(4)+1)
+		for j := range packs {
+			packs[j] = rnd.Intn(40) + 1
+		}
+
+		// a random shortfall half of the time.
+		tolerance := service.Tolerance{}
+		if rnd.Intn(2) == 0 {
+			tolerance.MaxShort = rnd.Intn(50)
+		}
+
+		reduced := tolerant(t, NewCalc(), tolerance)
+		exact := tolerant(t, NewMaxPacksCalc(math.MaxInt32), tolerance)
+
+		for j := 0; j < 20; j++ {
+			target := rnd.Intn(5000) + 1
+
+			want, err := exact.CalculatePacks(ctx, packs, target)
+			if err != nil {
+				t.Fatalf("packs %v, target %d: %v", packs, target, err)
+			}
+
+			got, err := reduced.CalculatePacks(ctx, packs, target)
+			if err != nil {
+				t.Fatalf("packs %v, target %d: %v", packs, target, err)
+			}
+
+			wantMap, gotMap := want.Map(), got.Map()
+			if len(wantMap) != len(gotMap) {
+				t.Fatalf("packs %v, target %d: got %v, want %v", packs, target, gotMap, wantMap)
+			}
+
+			for size, n := range wantMap {
+				if gotMap[size] != n {
+					t.Fatalf("packs %v, target %d: got %v, want %v", packs, target, gotMap, wantMap)
+				}
+			}
+		}
+	}
+}
+
+// Test_CalculatePacksReducedBruteForce compares the reduced calculation with
+// an exhaustive search on small inputs.
+func Test_CalculatePacksReducedBruteForce(t *testing.T) {
+	packs := []int{4, 9, 14}
+	c := NewCalc()
+
+	for target := 1; target <= 400; target++ {
+		res, err := c.CalculatePacks(context.Background(), packs, target)
+		if err != nil {
+			t.Fatalf("target %d: %v", target, err)
+		}
+
+		bestTotal, bestPacks := -1, 0
+		for x := 0; x <= target/4+1; x++ {
+			for y := 0; y <= target/9+1; y++ {
+				for z := 0; z <= target/14+1; z++ {
+					total := 4*x + 9*y + 14*z
+					if total < target {
+						continue
+					}
+
+					if bestTotal < 0 || total < bestTotal || (total == bestTotal && x+y+z < bestPacks) {
+						bestTotal, bestPacks = total, x+y+z
+					}
+				}
+			}
+		}
+
+		if res.TotalItems != bestTotal || res.TotalPacks != bestPacks {
+			t.Fatalf("target %d: got %d items in %d packs, want %d items in %d packs",
+				target, res.TotalItems, res.TotalPacks, bestTotal, bestPacks)
+		}
+	}
+}
+
+func Test_CalculatePacksHuge(t *testing.T) {
+	type testCaseInput struct {
+		packs         []int
+		orderQuantity int
+	}
+
+	type testCaseOutput struct {
+		result map[int]int
+		err    error
+	}
+
+	type testCase struct {
+		name   string
+		input  testCaseInput
+		output testCaseOutput
+	}
+
+	testCases := []testCase{
+		{
+			name: "2*10^9 items",
+			input: testCaseInput{
+				packs:         []int{250, 500, 1000, 2000, 5000},
+				orderQuantity: 2_000_000_001,
+			},
+			output: testCaseOutput{
+				result: map[int]int{5000: 400_000, 250: 1},
+			},
+		},
+		{
+			name: "10^18 items with co-prime packs",
+			input: testCaseInput{
+				packs:         []int{23, 31, 53},
+				orderQuantity: 1_000_000_000_000_000_000,
+			},
+			output: testCaseOutput{
+				// 18867924528301883 * 53 + 5 * 31 + 2 * 23 = 10^18
+				result: map[int]int{53: 18_867_924_528_301_883, 31: 5, 23: 2},
+			},
+		},
+		{
+			name: "int64 max items",
+			input: testCaseInput{
+				packs:         []int{1, 2},
+				orderQuantity: math.MaxInt64,
+			},
+			output: testCaseOutput{
+				result: map[int]int{2: math.MaxInt64 / 2, 1: 1},
+			},
+		},
+		{
+			name: "the packed items don't fit in an int",
+			input: testCaseInput{
+				packs:         []int{2, 4},
+				orderQuantity: math.MaxInt64,
+			},
+			output: testCaseOutput{
+				err: service.ErrTooLarge,
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewCalc()
+			res, err := c.CalculatePacks(context.Background(), tt.input.packs, tt.input.orderQuantity)
+			if !errors.Is(err, tt.output.err) {
+				t.Fatalf("got error %v, want %v", err, tt.output.err)
+			}
+
+			if tt.output.err != nil {
+				return
+			}
+
+			got := res.Map()
+			if len(got) != len(tt.output.result) {
+				t.Fatalf("got %v, want %v", got, tt.output.result)
+			}
+
+			for k, v := range tt.output.result {
+				if got[k] != v {
+					t.Fatalf("for required pack: %d: got %v, want %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func Test_CalculatePacksHugeOtherObjective(t *testing.T) {
+	// only the surplus objective can be reduced, the others need the whole table.
+	_, err := NewMinPacksCalc().CalculatePacks(context.Background(), []int{250, 500}, math.MaxInt64)
+	if !errors.Is(err, service.ErrTooLarge) {
+		t.Fatalf("got error %v, want %v", err, service.ErrTooLarge)
+	}
+
+	// their tables are capped far below the reduced one.
+	for _, c := range []*Calc{NewMinPacksCalc(), NewMinCostCalc(nil, 0), NewMaxPacksCalc(1_000_000)} {
+		_, err = c.CalculatePacks(context.Background(), []int{1, 7}, 60_000_000)
+		if !errors.Is(err, service.ErrTooLarge) {
+			t.Fatalf("got error %v, want %v", err, service.ErrTooLarge)
+		}
+	}
+
+	_, err = NewCalc().CalculateBoundedPacks(context.Background(), []int{1, 7}, map[int]int{1: 1 << 30}, 60_000_000)
+	if !errors.Is(err, service.ErrTooLarge) {
+		t.Fatalf("got error %v, want %v", err, service.ErrTooLarge)
+	}
+}
+
+func Test_CalculateBoundedPacksManyGroups(t *testing.T) {
+	// every size splits into 31 groups, their bitmaps would pass the cap.
+	stock := map[int]int{}
+	packs := make([]int, 40)
+	for i := range packs {
+		packs[i] = i + 1
+		stock[i+1] = 1<<31 - 1
+	}
+
+	_, err := NewCalc().CalculateBoundedPacks(context.Background(), packs, stock, 1_000_000)
+	if !errors.Is(err, service.ErrTooLarge) {
+		t.Fatalf("got error %v, want %v", err, service.ErrTooLarge)
+	}
+}
@@ -0,0 +1,47 @@
+package hierarchy
+
+import (
+	"context"
+	"reparttask/service"
+	"reparttask/storage"
+)
+
+// Plan is the packing of a single level, Contents holds the packing of the level inside it.
+type Plan struct {
+	Level    string         `json:"level"`
+	Packing  service.Result `json:"packing"`
+	Contents *Plan          `json:"contents,omitempty"`
+}
+
+// Calc packs every level of a packaging hierarchy with the rules of the wrapped calculator.
+type Calc struct {
+	calc service.Calculator
+}
+
+func NewCalc(calc service.Calculator) *Calc {
+	return &Calc{calc: calc}
+}
+
+// Plan takes the packing of the innermost level and packs its units into every
+// level in turn, the units of a level are the packs of the level below.
+// It returns the plan from the outermost level inwards.
+func (c *Calc) Plan(ctx context.Context, name string, base service.Result, levels []storage.Level) (Plan, error) {
+	plan := Plan{Level: name, Packing: base}
+
+	for _, level := range levels {
+		// nothing to put in the next level.
+		if plan.Packing.TotalPacks == 0 {
+			break
+		}
+
+		result, err := c.calc.CalculatePacks(ctx, level.Capacities, plan.Packing.TotalPacks)
+		if err != nil {
+			return Plan{}, err
+		}
+
+		inner := plan
+		plan = Plan{Level: level.Name, Packing: result, Contents: &inner}
+	}
+
+	return plan, nil
+}
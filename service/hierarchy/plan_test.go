@@ -0,0 +1,72 @@
+package hierarchy
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reparttask/service"
+	"reparttask/service/dp"
+	"reparttask/storage"
+	"testing"
+)
+
+type failingCalc struct{}
+
+func (c failingCalc) CalculatePacks(ctx context.Context, packs []int, orderQuantity int) (service.Result, error) {
+	return service.Result{}, service.ErrNoSolution
+}
+
+func Test_Plan(t *testing.T) {
+	ctx := context.Background()
+	calc := dp.NewCalc()
+
+	// 12001 items => 5000 x 2, 2000 x 1, 250 x 1, so 4 packs.
+	base, err := calc.CalculatePacks(ctx, []int{250, 500, 1000, 2000, 5000}, 12001)
+	assert.NoError(t, err)
+
+	levels := []storage.Level{
+		{Name: "carton", Capacities: []int{3, 6}},
+		{Name: "pallet", Capacities: []int{10}},
+	}
+
+	plan, err := NewCalc(calc).Plan(ctx, "pack", base, levels)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "pallet", plan.Level)
+	assert.Equal(t, map[int]int{10: 1}, plan.Packing.Map())
+	assert.Equal(t, 1, plan.Packing.OrderedItems)
+
+	carton := plan.Contents
+	assert.Equal(t, "carton", carton.Level)
+	assert.Equal(t, map[int]int{6: 1}, carton.Packing.Map())
+	assert.Equal(t, 4, carton.Packing.OrderedItems)
+	assert.Equal(t, 2, carton.Packing.SurplusItems)
+
+	pack := carton.Contents
+	assert.Equal(t, "pack", pack.Level)
+	assert.Equal(t, base, pack.Packing)
+	assert.Nil(t, pack.Contents)
+}
+
+func Test_PlanWithoutLevels(t *testing.T) {
+	base := service.NewResult("test", 250, map[int]int{250: 1})
+
+	plan, err := NewCalc(dp.NewCalc()).Plan(context.Background(), "pack", base, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Plan{Level: "pack", Packing: base}, plan)
+}
+
+func Test_PlanEmptyOrder(t *testing.T) {
+	base := service.NewResult("test", 0, nil)
+
+	plan, err := NewCalc(failingCalc{}).Plan(context.Background(), "pack", base, []storage.Level{{Name: "carton", Capacities: []int{10}}})
+	assert.NoError(t, err)
+	assert.Equal(t, "pack", plan.Level)
+}
+
+func Test_PlanError(t *testing.T) {
+	base := service.NewResult("test", 250, map[int]int{250: 1})
+
+	_, err := NewCalc(failingCalc{}).Plan(context.Background(), "pack", base, []storage.Level{{Name: "carton", Capacities: []int{10}}})
+	assert.True(t, errors.Is(err, service.ErrNoSolution))
+}
@@ -1,5 +1,39 @@
 package service
 
+import "context"
+
+// Calculator computes the packs needed for an order.
+// Implementations must be safe for concurrent use and must treat packs as read-only.
+// They stop early and return the context error once ctx is done.
 type Calculator interface {
-	CalculatePacks(input []int, orderQuantity int) map[int]int
+	CalculatePacks(ctx context.Context, input []int, orderQuantity int) (Result, error)
+}
+
+// BoundedCalculator is implemented by calculators that can respect limited pack stock.
+// stock holds the available number of packs for limited sizes, sizes missing from it are unlimited.
+type BoundedCalculator interface {
+	CalculateBoundedPacks(ctx context.Context, input []int, stock map[int]int, orderQuantity int) (Result, error)
+}
+
+// MaxAlternatives is the largest number of alternatives that can be requested.
+const MaxAlternatives = 20
+
+// AlternativesCalculator is implemented by calculators that can rank other packings
+// than the best one, and explain why one packing ranks above another.
+type AlternativesCalculator interface {
+	CalculateAlternatives(ctx context.Context, input []int, orderQuantity int, k int) ([]Result, error)
+	Explain(winner, runnerUp Result) string
+}
+
+// Tolerance sets how far the packed items may be from the ordered quantity.
+type Tolerance struct {
+	// MaxShort is the number of items the packing may fall short of the order.
+	MaxShort int
+	// MaxSurplus is the largest accepted surplus, nil means unlimited.
+	MaxSurplus *int
+}
+
+// TolerantCalculator is implemented by calculators that accept a tolerance per request.
+type TolerantCalculator interface {
+	WithTolerance(t Tolerance) (Calculator, error)
 }
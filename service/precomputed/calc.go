@@ -0,0 +1,160 @@
+package precomputed
+
+import (
+	"context"
+	"reparttask/service"
+	"reparttask/service/dp"
+	"sync"
+	"sync/atomic"
+)
+
+// Strategy is the name reported in results answered from the table.
+const Strategy = "precomputed"
+
+// maxUnits caps the table size, whatever the configured maximum quantity is.
+const maxUnits = 1 << 24
+
+// maxSteps caps the work of a build, every total of the table tries every pack size.
+const maxSteps = 1 << 26
+
+// maxSizes is the largest number of pack sizes a table is built for.
+// Larger pack sets always go to the fallback.
+const maxSizes = 1024
+
+// checkEvery is how many totals are built between two context checks.
+const checkEvery = 1 << 14
+
+// Calc answers orders from a table built for the current pack set, with the
+// same rule as dp.Calc: least surplus items, then fewest packs, preferring larger packs.
+// Orders it can't answer, for example while the table is built, go to the fallback.
+type Calc struct {
+	fallback    service.Calculator
+	maxQuantity int
+
+	current atomic.Pointer[table]
+
+	mu         sync.Mutex
+	generation int
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// New returns a calculator that precomputes answers up to maxQuantity items.
+func New(fallback service.Calculator, maxQuantity int) *Calc {
+	return &Calc{fallback: fallback, maxQuantity: maxQuantity}
+}
+
+// Rebuild starts building the table for packs in the background, any build
+// still running for an older pack set is cancelled. The previous table keeps
+// serving its own pack set until the new one is ready.
+func (c *Calc) Rebuild(packs []int) {
+	sizes := dp.Normalize(packs)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	c.generation++
+	generation := c.generation
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	c.cancel, c.done = cancel, done
+
+	go func() {
+		defer close(done)
+
+		t, err := build(ctx, sizes, c.maxQuantity)
+		if err != nil {
+			return
+		}
+
+		// only the latest build may replace the table.
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if generation == c.generation {
+			c.current.Store(t)
+		}
+	}()
+}
+
+// Wait blocks until the latest build has finished.
+func (c *Calc) Wait() {
+	c.mu.Lock()
+	done := c.done
+	c.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+func (c *Calc) CalculatePacks(ctx context.Context, packs []int, orderQuantity int) (service.Result, error) {
+	if orderQuantity <= 0 {
+		return service.NewResult(Strategy, orderQuantity, nil), nil
+	}
+
+	t := c.current.Load()
+	if t != nil && equal(t.sizes, dp.Normalize(packs)) {
+		if result, ok := t.answer(orderQuantity); ok {
+			return service.NewResult(Strategy, orderQuantity, result), nil
+		}
+	}
+
+	return c.fallback.CalculatePacks(ctx, packs, orderQuantity)
+}
+
+func (c *Calc) CalculateBoundedPacks(ctx context.Context, packs []int, stock map[int]int, orderQuantity int) (service.Result, error) {
+	bounded, ok := c.fallback.(service.BoundedCalculator)
+	if !ok {
+		return service.Result{}, service.ErrStockNotSupported
+	}
+
+	return bounded.CalculateBoundedPacks(ctx, packs, stock, orderQuantity)
+}
+
+func (c *Calc) CalculateAlternatives(ctx context.Context, packs []int, orderQuantity int, k int) ([]service.Result, error) {
+	alternatives, ok := c.fallback.(service.AlternativesCalculator)
+	if !ok {
+		return nil, service.ErrAlternativesNotSupported
+	}
+
+	return alternatives.CalculateAlternatives(ctx, packs, orderQuantity, k)
+}
+
+func (c *Calc) Explain(winner, runnerUp service.Result) string {
+	alternatives, ok := c.fallback.(service.AlternativesCalculator)
+	if !ok {
+		return ""
+	}
+
+	return alternatives.Explain(winner, runnerUp)
+}
+
+// WithTolerance returns the fallback calculator with the tolerance applied,
+// the table only holds the answers without a tolerance.
+func (c *Calc) WithTolerance(t service.Tolerance) (service.Calculator, error) {
+	tolerant, ok := c.fallback.(service.TolerantCalculator)
+	if !ok {
+		return nil, service.ErrModeNotSupported
+	}
+
+	return tolerant.WithTolerance(t)
+}
+
+func equal(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
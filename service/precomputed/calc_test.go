@@ -0,0 +1,205 @@
+package precomputed
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"reparttask/service"
+	"reparttask/service/dp"
+	"sync/atomic"
+	"testing"
+)
+
+// countingCalc counts the calculations that reach the fallback calculator.
+type countingCalc struct {
+	calls atomic.Int32
+}
+
+func (c *countingCalc) CalculatePacks(ctx context.Context, packs []int, orderQuantity int) (service.Result, error) {
+	c.calls.Add(1)
+	return dp.NewCalc().CalculatePacks(ctx, packs, orderQuantity)
+}
+
+func Test_CalculatePacksMatchesDp(t *testing.T) {
+	packSets := [][]int{
+		{250, 500, 1000, 2000, 5000},
+		{23, 31, 53},
+		{3, 5},
+		{6, 10, 15},
+		{7},
+		{4, 6},
+		{100, 250, 999},
+	}
+
+	ctx := context.Background()
+	exact := dp.NewCalc()
+
+	for _, packs := range packSets {
+		// a small table so that most quantities go through the periodic part.
+		c := New(&countingCalc{}, 500)
+		c.Rebuild(packs)
+		c.Wait()
+
+		quantities := []int{1, 2, 499, 500, 501, 12001, 100_000, 1_000_003}
+		for q := 1; q < 5000; q += 7 {
+			quantities = append(quantities, q)
+		}
+
+		for _, q := range quantities {
+			want, err := exact.CalculatePacks(ctx, packs, q)
+			assert.NoError(t, err)
+
+			got, err := c.CalculatePacks(ctx, packs, q)
+			assert.NoError(t, err)
+			assert.Equal(t, want.Map(), got.Map(), "packs %v quantity %d", packs, q)
+		}
+	}
+}
+
+func Test_CalculatePacksStrategy(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingCalc{}
+	c := New(inner, 1000)
+	packs := []int{250, 500, 1000, 2000, 5000}
+
+	// no table yet, the fallback answers.
+	res, err := c.CalculatePacks(ctx, packs, 12001)
+	assert.NoError(t, err)
+	assert.Equal(t, dp.Strategy, res.Strategy)
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	c.Rebuild(packs)
+	c.Wait()
+
+	res, err = c.CalculatePacks(ctx, []int{5000, 2000, 1000, 500, 250}, 12001)
+	assert.NoError(t, err)
+	assert.Equal(t, Strategy, res.Strategy)
+	assert.Equal(t, map[int]int{5000: 2, 2000: 1, 250: 1}, res.Map())
+	assert.Equal(t, 12001, res.OrderedItems)
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	// another pack set isn't answered from the table.
+	_, err = c.CalculatePacks(ctx, []int{250, 500}, 12001)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
+
+func Test_Rebuild(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingCalc{}
+	c := New(inner, 1000)
+
+	c.Rebuild([]int{250, 500})
+	c.Rebuild([]int{23, 31, 53})
+	c.Wait()
+
+	// only the latest pack set is kept.
+	res, err := c.CalculatePacks(ctx, []int{23, 31, 53}, 500_000)
+	assert.NoError(t, err)
+	assert.Equal(t, Strategy, res.Strategy)
+	assert.Equal(t, 500_000, res.TotalItems)
+
+	_, err = c.CalculatePacks(ctx, []int{250, 500}, 251)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), inner.calls.Load())
+
+	// an empty pack set leaves everything to the fallback.
+	c.Rebuild(nil)
+	c.Wait()
+	_, err = c.CalculatePacks(ctx, []int{23, 31, 53}, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), inner.calls.Load())
+}
+
+func Test_CalculatePacksNotPeriodic(t *testing.T) {
+	ctx := context.Background()
+	inner := &countingCalc{}
+	c := New(inner, 1000)
+
+	// the threshold is far above the table limit, so only the configured quantities are precomputed.
+	packs := []int{9973, 9967}
+	c.Rebuild(packs)
+	c.Wait()
+
+	res, err := c.CalculatePacks(ctx, packs, 1000)
+	assert.NoError(t, err)
+	assert.Equal(t, Strategy, res.Strategy)
+	assert.Equal(t, map[int]int{9967: 1}, res.Map())
+
+	_, err = c.CalculatePacks(ctx, packs, 100_000)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), inner.calls.Load())
+}
+
+func Test_Forwarding(t *testing.T) {
+	ctx := context.Background()
+
+	c := New(dp.NewCalc(), 1000)
+	res, err := c.CalculateBoundedPacks(ctx, []int{250, 500}, map[int]int{500: 0}, 251)
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{250: 2}, res.Map())
+
+	alternatives, err := c.CalculateAlternatives(ctx, []int{250, 500}, 251, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(alternatives))
+
+	c = New(&countingCalc{}, 1000)
+	_, err = c.CalculateBoundedPacks(ctx, []int{250}, map[int]int{250: 1}, 1)
+	assert.ErrorIs(t, err, service.ErrStockNotSupported)
+
+	_, err = c.CalculateAlternatives(ctx, []int{250}, 1, 2)
+	assert.ErrorIs(t, err, service.ErrAlternativesNotSupported)
+}
+
+func Test_CalculatePacksManySizes(t *testing.T) {
+	ctx := context.Background()
+
+	// more sizes than a byte can index, the largest ones must still be chosen.
+	packs := make([]int, 300)
+	for i := range packs {
+		packs[i] = i + 1
+	}
+
+	c := New(&countingCalc{}, 1000)
+	c.Rebuild(packs)
+	c.Wait()
+
+	for _, q := range []int{257, 300, 599, 1000} {
+		want, err := dp.NewCalc().CalculatePacks(ctx, packs, q)
+		assert.NoError(t, err)
+
+		got, err := c.CalculatePacks(ctx, packs, q)
+		assert.NoError(t, err)
+		assert.Equal(t, Strategy, got.Strategy)
+		assert.Equal(t, want.Map(), got.Map(), "quantity %d", q)
+	}
+}
+
+func Test_RebuildBounded(t *testing.T) {
+	ctx := context.Background()
+
+	// many sizes and a large maximum quantity, the table is capped by the build work.
+	packs := make([]int, 1000)
+	for i := range packs {
+		packs[i] = i + 1
+	}
+
+	c := New(&countingCalc{}, 1<<40)
+	c.Rebuild(packs)
+	c.Wait()
+
+	assert.LessOrEqual(t, len(c.current.Load().count), maxSteps/len(packs))
+	for _, q := range []int{999, 5000} {
+		want, err := dp.NewCalc().CalculatePacks(ctx, packs, q)
+		assert.NoError(t, err)
+
+		got, err := c.CalculatePacks(ctx, packs, q)
+		assert.NoError(t, err)
+		assert.Equal(t, Strategy, got.Strategy)
+		assert.Equal(t, want.Map(), got.Map(), "quantity %d", q)
+	}
+
+	// the threshold of these sizes doesn't fit in an int, nothing is precomputed.
+	c.Rebuild([]int{1 << 40, 1<<40 + 1})
+	c.Wait()
+	assert.Empty(t, c.current.Load().count)
+}
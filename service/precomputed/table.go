@@ -0,0 +1,141 @@
+package precomputed
+
+import (
+	"context"
+	"math"
+	"reparttask/service/dp"
+)
+
+// unreachable marks a total that cannot be built from the available packs.
+const unreachable = math.MaxUint32
+
+// table holds the best packing of every total up to its size, in gcd units.
+//
+// Above dp.Threshold the answers repeat with the largest pack L (in units):
+// answer(goal) = answer(goal - L) + one L.
+type table struct {
+	sizes []int
+	units []int
+	gcd   int
+	// count holds the fewest packs reaching every total, choice the last pack added.
+	// A packing is read back by following the choices down to zero.
+	count  []uint32
+	choice []uint16
+	// next holds the smallest reachable total >= goal, or -1.
+	next []int32
+	// periodic is set when the table reaches past the threshold by at least L,
+	// so larger orders can be reduced into it.
+	periodic bool
+}
+
+func build(ctx context.Context, sizes []int, maxQuantity int) (*table, error) {
+	// a table without sizes never matches the packs, so every order goes to the fallback.
+	if len(sizes) > maxSizes {
+		return &table{}, nil
+	}
+
+	t := &table{sizes: sizes}
+	if len(sizes) == 0 {
+		return t, nil
+	}
+
+	g, units := dp.Units(sizes)
+	t.gcd, t.units = g, units
+
+	n := len(units)
+	largest := units[n-1]
+
+	// every total tries every size, so the table is capped by the work it takes to build.
+	limit := min(maxUnits, maxSteps/n)
+	if largest >= limit {
+		return &table{}, nil
+	}
+	quantity := maxQuantity/g + min(maxQuantity%g, 1)
+
+	// cover the configured quantity plus one more largest pack, and reach past the threshold
+	// so that larger orders can be answered from the periodic part.
+	// When the threshold is out of reach only the configured quantity is covered.
+	threshold, ok := dp.Threshold(units)
+	size := 0
+	if ok && threshold <= limit && quantity <= limit {
+		size = max(quantity, threshold+largest) + largest + 1
+	}
+	t.periodic = size > 0 && size <= limit
+	if !t.periodic {
+		size = min(min(quantity, limit)+largest+1, limit)
+	}
+
+	t.count = make([]uint32, size)
+	t.choice = make([]uint16, size)
+	for total := 1; total < size; total++ {
+		if total%checkEvery == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		t.count[total] = unreachable
+		// going from the largest pack keeps the largest one on ties, like dp.Calc.
+		for i := n - 1; i >= 0; i-- {
+			u := units[i]
+			if u > total || t.count[total-u] == unreachable {
+				continue
+			}
+
+			if t.count[total-u]+1 < t.count[total] {
+				t.count[total] = t.count[total-u] + 1
+				t.choice[total] = uint16(i)
+			}
+		}
+	}
+
+	t.next = make([]int32, size)
+	next := int32(-1)
+	for total := size - 1; total >= 0; total-- {
+		if t.count[total] != unreachable {
+			next = int32(total)
+		}
+		t.next[total] = next
+	}
+
+	return t, nil
+}
+
+// answer returns the packing for quantity items, false when the table can't answer it.
+func (t *table) answer(quantity int) (map[int]int, bool) {
+	if len(t.next) == 0 {
+		return nil, false
+	}
+
+	n := len(t.sizes)
+	size := len(t.next)
+	largest := t.units[n-1]
+
+	goal := quantity/t.gcd + min(quantity%t.gcd, 1)
+	extra := 0
+	if goal >= size-largest {
+		if !t.periodic {
+			return nil, false
+		}
+
+		// move the goal below size - largest, every step is one more largest pack.
+		extra = (goal-(size-largest))/largest + 1
+		goal -= extra * largest
+	}
+
+	total := t.next[goal]
+	if total < 0 {
+		return nil, false
+	}
+
+	result := map[int]int{}
+	for rest := int(total); rest > 0; {
+		i := int(t.choice[rest])
+		result[t.sizes[i]]++
+		rest -= t.units[i]
+	}
+
+	if extra > 0 {
+		result[t.sizes[n-1]] += extra
+	}
+
+	return result, true
+}
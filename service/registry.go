@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	// ErrNoSolution is returned when no packing satisfies the requested objective.
+	ErrNoSolution = errors.New("no packing satisfies the requested objective")
+	// ErrTooLarge is returned when the order is too large for the requested calculation.
+	ErrTooLarge = errors.New("order is too large for the requested calculation")
+	// ErrStockNotSupported is returned when the calculator can't respect limited pack stock.
+	ErrStockNotSupported = errors.New("the selected calculator does not support limited pack stock")
+	// ErrAlternativesNotSupported is returned when the calculator can't rank alternatives.
+	ErrAlternativesNotSupported = errors.New("the selected calculator does not support alternatives")
+	// ErrModeNotSupported is returned when the calculator can't apply an order mode.
+	ErrModeNotSupported = errors.New("the selected calculator does not support order modes")
+)
+
+// Params holds the request level settings used to build an objective.
+type Params struct {
+	// MaxPacks limits the number of packs in a solution, 0 means no limit.
+	MaxPacks int
+	// Costs holds the cost of each pack size in the smallest currency unit.
+	Costs map[int]int64
+	// SurplusCost is the cost of every item shipped above the ordered quantity.
+	SurplusCost int64
+}
+
+// Factory builds a Calculator for the given params.
+type Factory func(p Params) (Calculator, error)
+
+// Registry holds the named objectives callers can choose from.
+// All objectives must be registered before the registry is used by handlers.
+type Registry struct {
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: map[string]Factory{}}
+}
+
+// Register adds a named objective, registering the same name twice replaces the first one.
+func (r *Registry) Register(name string, f Factory) {
+	r.factories[name] = f
+}
+
+// New builds the calculator for the named objective.
+func (r *Registry) New(name string, p Params) (Calculator, error) {
+	f, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown objective %q", name)
+	}
+
+	return f(p)
+}
+
+// Names returns the registered objective names in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"reparttask/storage"
+	"sort"
+)
+
+// PackLine is the number of packs used for a single pack size.
+type PackLine struct {
+	Size     int   `json:"size"`
+	Quantity int   `json:"quantity"`
+	UnitCost int64 `json:"unit_cost"`
+	Cost     int64 `json:"cost"`
+	PackInfo
+}
+
+// PackInfo describes a pack size for labels and carriers, every field is optional.
+type PackInfo struct {
+	Name       string              `json:"name,omitempty"`
+	Barcode    string              `json:"barcode,omitempty"`
+	Dimensions *storage.Dimensions `json:"dimensions,omitempty"`
+	// TareWeight is the weight of the empty pack, in grams.
+	TareWeight int `json:"tare_weight,omitempty"`
+}
+
+// CostBreakdown splits the cost of a packing, in the smallest currency unit.
+type CostBreakdown struct {
+	Packs   int64 `json:"packs"`
+	Surplus int64 `json:"surplus"`
+	Total   int64 `json:"total"`
+}
+
+// Result describes a computed packing for an order,
+// the total weight is the tare weight of every pack in grams.
+type Result struct {
+	Packs        []PackLine    `json:"packs"`
+	OrderedItems int           `json:"ordered_items"`
+	TotalPacks   int           `json:"total_packs"`
+	TotalItems   int           `json:"total_items"`
+	SurplusItems int           `json:"surplus_items"`
+	ShortItems   int           `json:"short_items"`
+	Cost         CostBreakdown `json:"cost"`
+	TotalWeight  int           `json:"total_weight"`
+	Strategy     string        `json:"strategy"`
+}
+
+// NewResult builds a Result from a pack size => count map, lines are sorted by size.
+func NewResult(strategy string, orderQuantity int, packs map[int]int) Result {
+	result := Result{
+		Packs:        []PackLine{},
+		OrderedItems: orderQuantity,
+		Strategy:     strategy,
+	}
+
+	for size, qty := range packs {
+		if qty <= 0 {
+			continue
+		}
+
+		result.Packs = append(result.Packs, PackLine{Size: size, Quantity: qty})
+		result.TotalPacks += qty
+		result.TotalItems += size * qty
+	}
+
+	sort.Slice(result.Packs, func(i, j int) bool {
+		return result.Packs[i].Size < result.Packs[j].Size
+	})
+
+	if result.TotalPacks > 0 && result.TotalItems > orderQuantity {
+		result.SurplusItems = result.TotalItems - orderQuantity
+	}
+
+	// only orders allowed to ship short can end up below the ordered quantity.
+	if result.TotalItems < orderQuantity {
+		result.ShortItems = orderQuantity - result.TotalItems
+	}
+
+	return result
+}
+
+// DefaultUnitCost is the cost of a pack without a configured cost,
+// both when minimising the cost and when pricing a result.
+const DefaultUnitCost = 1
+
+// UnitCost returns the cost of a pack of size, DefaultUnitCost when costs has none.
+func UnitCost(costs map[int]int64, size int) int64 {
+	if cost, ok := costs[size]; ok {
+		return cost
+	}
+
+	return DefaultUnitCost
+}
+
+// WithCosts returns a copy of the result priced with the given pack costs
+// and the cost of every surplus item, see UnitCost.
+func (r Result) WithCosts(costs map[int]int64, surplusCost int64) Result {
+	lines := make([]PackLine, len(r.Packs))
+	r.Cost = CostBreakdown{}
+	for i, line := range r.Packs {
+		line.UnitCost = UnitCost(costs, line.Size)
+		line.Cost = line.UnitCost * int64(line.Quantity)
+		lines[i] = line
+
+		r.Cost.Packs += line.Cost
+	}
+
+	r.Packs = lines
+	r.Cost.Surplus = surplusCost * int64(r.SurplusItems)
+	r.Cost.Total = r.Cost.Packs + r.Cost.Surplus
+
+	return r
+}
+
+// WithPackInfo returns a copy of the result describing every pack line
+// with the pack size => info map, and the total weight of the packs.
+func (r Result) WithPackInfo(info map[int]PackInfo) Result {
+	lines := make([]PackLine, len(r.Packs))
+	r.TotalWeight = 0
+	for i, line := range r.Packs {
+		line.PackInfo = info[line.Size]
+		lines[i] = line
+
+		r.TotalWeight += line.TareWeight * line.Quantity
+	}
+
+	r.Packs = lines
+	return r
+}
+
+// Map returns the packing as a pack size => count map, the original response shape.
+func (r Result) Map() map[int]int {
+	m := make(map[int]int, len(r.Packs))
+	for _, line := range r.Packs {
+		m[line.Size] = line.Quantity
+	}
+
+	return m
+}
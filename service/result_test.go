@@ -0,0 +1,118 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"reparttask/storage"
+	"testing"
+)
+
+func Test_NewResult(t *testing.T) {
+	tests := []struct {
+		name          string
+		orderQuantity int
+		packs         map[int]int
+		want          Result
+	}{
+		{
+			name:          "test lines sorted by size with totals",
+			orderQuantity: 12001,
+			packs:         map[int]int{5000: 2, 250: 1, 2000: 1},
+			want: Result{
+				Packs:        []PackLine{{Size: 250, Quantity: 1}, {Size: 2000, Quantity: 1}, {Size: 5000, Quantity: 2}},
+				OrderedItems: 12001,
+				TotalPacks:   4,
+				TotalItems:   12250,
+				SurplusItems: 249,
+				Strategy:     "test",
+			},
+		},
+		{
+			name:          "test empty packing",
+			orderQuantity: -1,
+			packs:         map[int]int{},
+			want: Result{
+				Packs:        []PackLine{},
+				OrderedItems: -1,
+				Strategy:     "test",
+			},
+		},
+		{
+			name:          "test short packing",
+			orderQuantity: 255,
+			packs:         map[int]int{250: 1},
+			want: Result{
+				Packs:        []PackLine{{Size: 250, Quantity: 1}},
+				OrderedItems: 255,
+				TotalPacks:   1,
+				TotalItems:   250,
+				ShortItems:   5,
+				Strategy:     "test",
+			},
+		},
+		{
+			name:          "test zero counts are skipped",
+			orderQuantity: 250,
+			packs:         map[int]int{250: 1, 500: 0},
+			want: Result{
+				Packs:        []PackLine{{Size: 250, Quantity: 1}},
+				OrderedItems: 250,
+				TotalPacks:   1,
+				TotalItems:   250,
+				Strategy:     "test",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewResult("test", tt.orderQuantity, tt.packs)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, len(tt.want.Packs), len(got.Map()))
+		})
+	}
+}
+
+func Test_WithCosts(t *testing.T) {
+	res := NewResult("test", 12001, map[int]int{5000: 2, 250: 1, 2000: 1})
+	costs := map[int]int64{250: 10, 2000: 50, 5000: 100}
+
+	got := res.WithCosts(costs, 2)
+
+	assert.Equal(t, []PackLine{
+		{Size: 250, Quantity: 1, UnitCost: 10, Cost: 10},
+		{Size: 2000, Quantity: 1, UnitCost: 50, Cost: 50},
+		{Size: 5000, Quantity: 2, UnitCost: 100, Cost: 200},
+	}, got.Packs)
+	assert.Equal(t, CostBreakdown{Packs: 260, Surplus: 498, Total: 758}, got.Cost)
+
+	// the original result must not be priced.
+	assert.Equal(t, int64(0), res.Packs[0].Cost)
+
+	// a pack without a cost is priced at the default unit cost, a free one at zero.
+	got = res.WithCosts(map[int]int64{250: 0}, 0)
+	assert.Equal(t, []PackLine{
+		{Size: 250, Quantity: 1, UnitCost: 0, Cost: 0},
+		{Size: 2000, Quantity: 1, UnitCost: DefaultUnitCost, Cost: DefaultUnitCost},
+		{Size: 5000, Quantity: 2, UnitCost: DefaultUnitCost, Cost: 2 * DefaultUnitCost},
+	}, got.Packs)
+}
+
+func Test_WithPackInfo(t *testing.T) {
+	res := NewResult("test", 12001, map[int]int{5000: 2, 250: 1, 2000: 1})
+	small := PackInfo{Name: "Small box", Barcode: "SB-250", Dimensions: &storage.Dimensions{Length: 300, Width: 200, Height: 100}, TareWeight: 120}
+	large := PackInfo{Name: "Pallet box", TareWeight: 2500}
+
+	// 2000 has no info, so it doesn't add any weight.
+	got := res.WithPackInfo(map[int]PackInfo{250: small, 5000: large})
+
+	assert.Equal(t, []PackLine{
+		{Size: 250, Quantity: 1, PackInfo: small},
+		{Size: 2000, Quantity: 1},
+		{Size: 5000, Quantity: 2, PackInfo: large},
+	}, got.Packs)
+	assert.Equal(t, 5120, got.TotalWeight)
+
+	// the original result must not be described.
+	assert.Equal(t, "", res.Packs[0].Name)
+	assert.Equal(t, 0, res.TotalWeight)
+}
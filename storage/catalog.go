@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultSKU is the product used by the endpoints that are not scoped to a product.
+const DefaultSKU = "default"
+
+var (
+	// ErrInvalidSKU is returned for a product SKU that can't be used.
+	ErrInvalidSKU = Errorf(ErrInvalid, "sku must be 1 to 64 letters, digits, '.', '_' or '-', starting with a letter or digit")
+	// ErrProductsNotSupported is returned when the catalog can't add products.
+	ErrProductsNotSupported = Errorf(ErrInvalid, "products are not supported")
+)
+
+// skuPattern requires a leading letter or digit, so that "." and ".." can't name a product directory.
+var skuPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Catalog holds a separate pack storage for every product.
+type Catalog interface {
+	// Lookup returns the storage of an existing product.
+	Lookup(sku string) (Storage, bool)
+	// Product returns the storage of sku, an empty one is added for a new product.
+	Product(sku string) (Storage, error)
+	// Products returns the SKUs of every product, sorted.
+	Products() []string
+}
+
+// Products is a Catalog keeping every product storage in memory.
+type Products struct {
+	create func(sku string) (Storage, error)
+
+	mu       sync.RWMutex
+	products map[string]Storage
+}
+
+// NewProducts returns a catalog holding db as the default product,
+// the storage of new products is built by create. A nil create only allows the default product.
+func NewProducts(db Storage, create func(sku string) (Storage, error)) *Products {
+	return &Products{create: create, products: map[string]Storage{DefaultSKU: db}}
+}
+
+func (p *Products) Lookup(sku string) (Storage, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	db, ok := p.products[sku]
+	return db, ok
+}
+
+func (p *Products) Product(sku string) (Storage, error) {
+	if db, ok := p.Lookup(sku); ok {
+		return db, nil
+	}
+
+	if !ValidSKU(sku) {
+		return nil, ErrInvalidSKU
+	}
+
+	if p.create == nil {
+		return nil, fmt.Errorf("%w: can't add %q", ErrProductsNotSupported, sku)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// another request may have added it in the meantime.
+	if db, ok := p.products[sku]; ok {
+		return db, nil
+	}
+
+	db, err := p.create(sku)
+	if err != nil {
+		return nil, err
+	}
+
+	p.products[sku] = db
+	return db, nil
+}
+
+func (p *Products) Products() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	skus := make([]string, 0, len(p.products))
+	for sku := range p.products {
+		skus = append(skus, sku)
+	}
+
+	sort.Strings(skus)
+	return skus
+}
+
+// ValidSKU reports whether sku can name a product.
+func ValidSKU(sku string) bool {
+	return skuPattern.MatchString(sku)
+}
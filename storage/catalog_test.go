@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestProducts(t *testing.T) {
+	def := &dbMock{}
+	p := NewProducts(def, func(string) (Storage, error) { return &dbMock{}, nil })
+
+	db, ok := p.Lookup(DefaultSKU)
+	assert.True(t, ok)
+	assert.Same(t, def, db)
+
+	_, ok = p.Lookup("SKU-1")
+	assert.False(t, ok)
+
+	created, err := p.Product("SKU-1")
+	assert.NoError(t, err)
+	assert.NotSame(t, def, created)
+
+	// the same product is returned afterwards.
+	again, err := p.Product("SKU-1")
+	assert.NoError(t, err)
+	assert.Same(t, created, again)
+
+	assert.Equal(t, []string{"SKU-1", DefaultSKU}, p.Products())
+}
+
+func TestProductsInvalidSKU(t *testing.T) {
+	p := NewProducts(&dbMock{}, func(string) (Storage, error) { return &dbMock{}, nil })
+
+	for _, sku := range []string{"", "a b", "a/b", ".", "..", ".hidden", "-a", strings.Repeat("a", 65)} {
+		_, err := p.Product(sku)
+		assert.True(t, errors.Is(err, ErrInvalidSKU), sku)
+	}
+}
+
+func TestProductsWithoutCreate(t *testing.T) {
+	p := NewProducts(&dbMock{}, nil)
+
+	_, err := p.Product(DefaultSKU)
+	assert.NoError(t, err)
+
+	_, err = p.Product("SKU-1")
+	assert.True(t, errors.Is(err, ErrProductsNotSupported))
+}
+
+func TestProductsCreateError(t *testing.T) {
+	p := NewProducts(&dbMock{}, func(string) (Storage, error) { return nil, errors.New("disk full") })
+
+	_, err := p.Product("SKU-1")
+	assert.EqualError(t, err, "disk full")
+
+	// a failed product is not added.
+	_, ok := p.Lookup("SKU-1")
+	assert.False(t, ok)
+}
+
+func TestProductsConcurrent(t *testing.T) {
+	p := NewProducts(&dbMock{}, func(string) (Storage, error) { return &dbMock{}, nil })
+
+	var wg sync.WaitGroup
+	dbs := make([]Storage, 50)
+	for i := range dbs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dbs[i], _ = p.Product("SKU-1")
+		}()
+	}
+	wg.Wait()
+
+	for _, db := range dbs {
+		assert.Same(t, dbs[0], db)
+	}
+}
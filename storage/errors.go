@@ -0,0 +1,40 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors reported by every storage, the handlers translate them to status codes.
+var (
+	// ErrNotFound is returned when a pack size or a product doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidSize is returned for a pack size that is not positive.
+	ErrInvalidSize = errors.New("invalid pack size")
+	// ErrInvalid is returned for the other pack, level or product values that fail validation.
+	ErrInvalid = errors.New("invalid value")
+	// ErrConflict is returned when a change conflicts with the stored state.
+	ErrConflict = errors.New("conflict")
+	// ErrInsufficientStock is returned when the packs in stock can't cover an order or a reservation,
+	// by the storage and by the calculators respecting the stock alike.
+	ErrInsufficientStock = Errorf(ErrConflict, "cannot fulfil order with the available pack stock")
+)
+
+// kindError has a message of its own and matches one of the sentinel errors with errors.Is.
+type kindError struct {
+	kind error
+	msg  string
+}
+
+// Errorf returns an error with the formatted message that matches kind, one of the sentinel errors.
+func Errorf(kind error, format string, args ...any) error {
+	return &kindError{kind: kind, msg: fmt.Sprintf(format, args...)}
+}
+
+func (e *kindError) Error() string {
+	return e.msg
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestErrorf(t *testing.T) {
+	err := Errorf(ErrNotFound, "size %d not found", 250)
+	assert.EqualError(t, err, "size 250 not found")
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.False(t, errors.Is(err, ErrConflict))
+
+	// the kind is still matched once the error is wrapped.
+	wrapped := fmt.Errorf("%w: size 250 has 0 left", ErrInsufficientStock)
+	assert.True(t, errors.Is(wrapped, ErrInsufficientStock))
+	assert.True(t, errors.Is(wrapped, ErrConflict))
+}
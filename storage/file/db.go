@@ -0,0 +1,302 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// compactEvery is the number of changes after which the log is compacted into a snapshot.
+const compactEvery = 1000
+
+// FileDB is a storage persisted to a data directory.
+// Every change is appended to a log and synced to disk before it becomes visible,
+// and the log is compacted into a snapshot every compactEvery changes.
+// On startup the snapshot is loaded and the log replayed on top of it,
+// a record torn by a crash at the end of the log is dropped.
+type FileDB struct {
+	dir          string
+	compactEvery int
+
+	// mu serializes the changes, reads only load the current state.
+	mu      sync.Mutex
+	log     *os.File
+	size    int64
+	seq     uint64
+	pending int
+	state   atomic.Pointer[memory.MemDB]
+	// now is kept apart from mu, so that reading the clock never waits for a disk sync.
+	now atomic.Pointer[func() time.Time]
+}
+
+// NewFileDB opens the storage kept in dir, the directory is created when missing.
+func NewFileDB(dir string) (*FileDB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	state, seq, err := loadSnapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, logFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	seq, pending, size, err := replay(data, state, seq)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openLog(path, size, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	db := &FileDB{dir: dir, compactEvery: compactEvery, log: f, size: int64(size), seq: seq, pending: pending}
+	db.state.Store(state)
+	db.SetClock(time.Now)
+	return db, nil
+}
+
+// Stored reports whether dir holds a storage, a log or a snapshot.
+func Stored(dir string) bool {
+	for _, name := range []string{logFile, snapshotFile} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (db *FileDB) AddPacks(ctx context.Context, packs []storage.Pack) error {
+	return db.change(ctx, record{Op: opAdd, Packs: packs})
+}
+
+func (db *FileDB) RemovePack(ctx context.Context, size int) error {
+	return db.change(ctx, record{Op: opRemove, Size: size})
+}
+
+func (db *FileDB) RemovePacks(ctx context.Context) error {
+	return db.change(ctx, record{Op: opRemoveAll})
+}
+
+// ReplacePacks replaces every pack, unless version is AnyVersion it must be the latest version.
+func (db *FileDB) ReplacePacks(ctx context.Context, packs []storage.Pack, version int) error {
+	return db.change(ctx, record{Op: opReplace, Packs: packs, Version: &version})
+}
+
+// GetPacks returns a snapshot of the stored packs sorted by size.
+func (db *FileDB) GetPacks(ctx context.Context) ([]storage.Pack, error) {
+	return db.state.Load().GetPacks(ctx)
+}
+
+// ReservePacks takes the given packs out of stock, sizes with unlimited stock are left as they are.
+func (db *FileDB) ReservePacks(ctx context.Context, packs map[int]int) error {
+	return db.change(ctx, record{Op: opReserve, Reserve: packs})
+}
+
+// SetLevels replaces the packaging hierarchy, an empty one leaves only the packs.
+func (db *FileDB) SetLevels(ctx context.Context, levels []storage.Level) error {
+	return db.change(ctx, record{Op: opLevels, Levels: levels})
+}
+
+func (db *FileDB) GetLevels(ctx context.Context) ([]storage.Level, error) {
+	return db.state.Load().GetLevels(ctx)
+}
+
+// History returns every version of the packs, oldest first.
+func (db *FileDB) History(ctx context.Context) ([]storage.PackSet, error) {
+	return db.state.Load().History(ctx)
+}
+
+func (db *FileDB) PackSet(ctx context.Context, version int) (storage.PackSet, error) {
+	return db.state.Load().PackSet(ctx, version)
+}
+
+// Rollback restores the packs of a version as a new version.
+func (db *FileDB) Rollback(ctx context.Context, version int) error {
+	return db.change(ctx, record{Op: opRollback, Target: version})
+}
+
+// SchedulePacks schedules the packs to replace every pack from the given instant.
+func (db *FileDB) SchedulePacks(ctx context.Context, packs []storage.Pack, from time.Time) (storage.Schedule, error) {
+	next, err := db.commit(ctx, record{Op: opSchedule, Packs: packs, From: &from})
+	if err != nil {
+		return storage.Schedule{}, err
+	}
+
+	// the schedule is already stored, the state is read whether ctx is done by now or not.
+	// IDs grow with every schedule, the new one has the largest.
+	var schedule storage.Schedule
+	for _, s := range next.State().Schedules {
+		if s.ID > schedule.ID {
+			schedule = s
+		}
+	}
+
+	return schedule, nil
+}
+
+func (db *FileDB) Schedules(ctx context.Context) ([]storage.Schedule, error) {
+	return db.state.Load().Schedules(ctx)
+}
+
+func (db *FileDB) CancelSchedule(ctx context.Context, id int) error {
+	return db.change(ctx, record{Op: opCancel, Target: id})
+}
+
+// ApplySchedules applies the due scheduled changes, it only writes to the log when one is due.
+// The other changes apply them as part of their own record, replay does the same at the logged time.
+func (db *FileDB) ApplySchedules(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	schedules, err := db.state.Load().Schedules(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// most runs of the scheduler find nothing due, they must not grow the log.
+	now := db.clock()
+	due := 0
+	for _, schedule := range schedules {
+		if !schedule.EffectiveFrom.After(now) {
+			due++
+		}
+	}
+
+	if due == 0 {
+		return 0, nil
+	}
+
+	return due, db.change(ctx, record{Op: opApply})
+}
+
+// ActivePackSet returns the version of the packs active at the given instant, or now when it is zero.
+func (db *FileDB) ActivePackSet(ctx context.Context, at time.Time) (storage.PackSet, error) {
+	// the state is stamped with the time of its last change, not the current one.
+	if at.IsZero() {
+		at = db.clock()
+	}
+
+	return db.state.Load().ActivePackSet(ctx, at)
+}
+
+// SetClock replaces the clock stamping the versions and telling when schedules are due, time.Now by default.
+func (db *FileDB) SetClock(now func() time.Time) {
+	db.now.Store(&now)
+}
+
+// clock returns the current time of the clock.
+func (db *FileDB) clock() time.Time {
+	return (*db.now.Load())()
+}
+
+// Compact writes the current state into the snapshot and empties the log.
+func (db *FileDB) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.compact()
+}
+
+// Close closes the log, the storage can't be changed afterwards.
+func (db *FileDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.log.Close()
+}
+
+// change applies the record on a copy of the state, appends it to the log and
+// only then publishes the new state, so a failed change or write leaves no trace.
+func (db *FileDB) change(ctx context.Context, r record) error {
+	_, err := db.commit(ctx, r)
+	return err
+}
+
+// commit is change returning the new state.
+func (db *FileDB) commit(ctx context.Context, r record) (*memory.MemDB, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// a change waiting for the lock may have been cancelled in the meantime.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the time & actor are logged, so that a replayed change records the same version.
+	r.At, r.Actor = db.clock().UTC(), storage.Actor(ctx)
+	next := db.state.Load().Clone()
+	next.SetClock(r.clock)
+	if err := r.apply(ctx, next); err != nil {
+		return nil, err
+	}
+
+	r.Seq = db.seq + 1
+	if err := db.append(r); err != nil {
+		return nil, err
+	}
+
+	db.seq = r.Seq
+	db.state.Store(next)
+
+	// the change is already durable, a failed compaction is tried again with the next change.
+	db.pending++
+	if db.pending >= db.compactEvery {
+		_ = db.compact()
+	}
+
+	return next, nil
+}
+
+// append writes the record at the end of the log and syncs it.
+func (db *FileDB) append(r record) error {
+	line, err := r.encode()
+	if err != nil {
+		return err
+	}
+
+	_, err = db.log.Write(line)
+	if err == nil {
+		err = db.log.Sync()
+	}
+	if err != nil {
+		// a partial record would corrupt the log once the next one is written after it.
+		_ = db.log.Truncate(db.size)
+		return err
+	}
+
+	db.size += int64(len(line))
+	return nil
+}
+
+func (db *FileDB) compact() error {
+	err := writeSnapshot(db.dir, snapshot{Seq: db.seq, State: db.state.Load().State()})
+	if err != nil {
+		return err
+	}
+
+	// a crash before the log is emptied is harmless, replay skips the records in the snapshot.
+	err = db.log.Truncate(0)
+	if err == nil {
+		err = db.log.Sync()
+	}
+	if err != nil {
+		return err
+	}
+
+	db.size, db.pending = 0, 0
+	return nil
+}
@@ -0,0 +1,359 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"reparttask/storage/storagetest"
+	"testing"
+	"time"
+)
+
+var ctx = context.Background()
+
+// packs returns the stored packs, failing the test on error.
+func packs(t *testing.T, db storage.Storage) []storage.Pack {
+	t.Helper()
+
+	packs, err := db.GetPacks(ctx)
+	assert.NoError(t, err)
+	return packs
+}
+
+// levels returns the stored levels, failing the test on error.
+func levels(t *testing.T, db storage.Storage) []storage.Level {
+	t.Helper()
+
+	levels, err := db.GetLevels(ctx)
+	assert.NoError(t, err)
+	return levels
+}
+
+func open(t *testing.T, dir string) *FileDB {
+	t.Helper()
+
+	db, err := NewFileDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// fill changes db with every kind of record.
+func fill(t *testing.T, db *FileDB) {
+	t.Helper()
+
+	stock := 5
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 250, Cost: cost(10), Stock: &stock}, {Size: 500, Name: "Medium box"}, {Size: 1000}}))
+	assert.NoError(t, db.RemovePack(ctx, 1000))
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 2}))
+	assert.NoError(t, db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{6}}}))
+}
+
+func assertFilled(t *testing.T, db *FileDB) {
+	t.Helper()
+
+	three := 3
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(10), Stock: &three}, {Size: 500, Name: "Medium box"}}, packs(t, db))
+	assert.Equal(t, []storage.Level{{Name: "carton", Capacities: []int{6}}}, levels(t, db))
+}
+
+func TestFileDBConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage { return open(t, t.TempDir()) })
+}
+
+func TestFileDB(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	fill(t, db)
+	assertFilled(t, db)
+	assert.NoError(t, db.Close())
+
+	// the packs survive a restart.
+	db = open(t, dir)
+	assertFilled(t, db)
+
+	assert.NoError(t, db.RemovePacks(ctx))
+	assert.NoError(t, db.Close())
+
+	db = open(t, dir)
+	assert.Empty(t, packs(t, db))
+
+	// a replace checked against its version is replayed whatever the version then.
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 750}}, 3))
+	assert.NoError(t, db.Close())
+
+	db = open(t, dir)
+	assert.Equal(t, []int{750}, storage.Sizes(packs(t, db)))
+}
+
+func TestFileDBHistory(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	db.SetClock(func() time.Time { return now })
+
+	fill(t, db)
+	now = now.Add(time.Hour)
+	assert.NoError(t, db.Rollback(storage.WithActor(ctx, "alice"), 1))
+
+	history, err := db.History(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, history, 3)
+	assert.NoError(t, db.Close())
+
+	// the versions are replayed with their time & actor.
+	db = open(t, dir)
+	replayed, err := db.History(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, history, replayed)
+
+	// and kept by the snapshot.
+	assert.NoError(t, db.Compact())
+	assert.NoError(t, db.Close())
+
+	db = open(t, dir)
+	compacted, err := db.History(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, history, compacted)
+	assert.Equal(t, "alice", compacted[2].Actor)
+	assert.Equal(t, now, compacted[2].CreatedAt)
+}
+
+func TestFileDBSchedules(t *testing.T) {
+	dir := t.TempDir()
+
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	db := open(t, dir)
+	db.SetClock(clock)
+	fill(t, db)
+
+	first, err := db.SchedulePacks(ctx, []storage.Pack{{Size: 1000}}, now.Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = db.SchedulePacks(ctx, []storage.Pack{{Size: 2000}}, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+
+	info, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the scheduler finding nothing due doesn't grow the log.
+	applied, err := db.ApplySchedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, applied)
+
+	after, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info.Size(), after.Size())
+
+	now = now.Add(time.Hour)
+	applied, err = db.ApplySchedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	assert.NoError(t, db.Close())
+
+	// the schedules are replayed, the applied one at the time it was applied.
+	db = open(t, dir)
+	db.SetClock(clock)
+	assert.Equal(t, []int{1000}, storage.Sizes(packs(t, db)))
+
+	schedules, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 1)
+
+	// and kept by the snapshot, with the IDs already used.
+	assert.NoError(t, db.Compact())
+	assert.NoError(t, db.Close())
+
+	db = open(t, dir)
+	db.SetClock(clock)
+	compacted, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, schedules, compacted)
+
+	history, err := db.History(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, history[len(history)-1].Schedule)
+	assert.Equal(t, now, history[len(history)-1].CreatedAt)
+
+	s, err := db.SchedulePacks(ctx, []storage.Pack{{Size: 500}}, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, s.ID)
+}
+
+func TestFileDBReadsDuringChange(t *testing.T) {
+	db := open(t, t.TempDir())
+	fill(t, db)
+
+	// a change holding the lock, eg. while the log is synced, doesn't block the reads.
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := db.ActivePackSet(ctx, time.Time{})
+		assert.NoError(t, err)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the read waited for the change")
+	}
+}
+
+func TestFileDBFailedChange(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	fill(t, db)
+
+	info, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// failed changes are not logged.
+	assert.EqualError(t, db.RemovePack(ctx, 1000), "size 1000 not found")
+	assert.True(t, errors.Is(db.ReservePacks(ctx, map[int]int{250: 4}), storage.ErrInsufficientStock))
+	assert.Error(t, db.AddPacks(ctx, []storage.Pack{{Size: 2000}, {Size: -1}}))
+	assertFilled(t, db)
+
+	after, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, info.Size(), after.Size())
+}
+
+func TestFileDBCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	db.compactEvery = 2
+	fill(t, db)
+
+	// the 4 changes were compacted twice, so the log is empty.
+	info, err := os.Stat(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(0), info.Size())
+
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 2000}}))
+	assert.NoError(t, db.Close())
+
+	// the snapshot is loaded and the last change replayed on top of it.
+	db = open(t, dir)
+	assert.Equal(t, []int{250, 500, 2000}, storage.Sizes(packs(t, db)))
+	assert.Equal(t, 3, *packs(t, db)[0].Stock)
+}
+
+func TestFileDBCrashDuringCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	fill(t, db)
+
+	log, err := os.ReadFile(filepath.Join(dir, logFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, db.Compact())
+	assert.NoError(t, db.Close())
+
+	// the snapshot was written but the log not emptied yet,
+	// the stock must not be reserved twice.
+	if err := os.WriteFile(filepath.Join(dir, logFile), log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db = open(t, dir)
+	assertFilled(t, db)
+}
+
+func TestFileDBTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	fill(t, db)
+	assert.NoError(t, db.Close())
+
+	path := filepath.Join(dir, logFile)
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := record{Seq: 5, Op: opRemoveAll}.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tails := map[string][]byte{
+		"partial record":  line[:len(line)/2],
+		"missing newline": line[:len(line)-1],
+		"bad checksum":    append([]byte("00000000"), line[8:]...),
+	}
+
+	for name, tail := range tails {
+		t.Run(name, func(t *testing.T) {
+			if err := os.WriteFile(path, append(append([]byte{}, log...), tail...), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			// the torn record is dropped and the log truncated after the last complete one.
+			db := open(t, dir)
+			assertFilled(t, db)
+
+			assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 2000}}))
+			assert.NoError(t, db.Close())
+
+			db = open(t, dir)
+			assert.Equal(t, []int{250, 500, 2000}, storage.Sizes(packs(t, db)))
+			assert.NoError(t, db.RemovePack(ctx, 2000))
+		})
+	}
+}
+
+func TestFileDBCorrupted(t *testing.T) {
+	dir := t.TempDir()
+
+	db := open(t, dir)
+	fill(t, db)
+	assert.NoError(t, db.Close())
+
+	path := filepath.Join(dir, logFile)
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a damaged record followed by complete ones can't be a torn write.
+	log[3] ^= 0xff
+	if err := os.WriteFile(path, log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewFileDB(dir)
+	assert.EqualError(t, err, "the log is corrupted at offset 0")
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
+}
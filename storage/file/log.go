@@ -0,0 +1,293 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"reparttask/storage/memory"
+	"strconv"
+	"time"
+)
+
+const (
+	logFile      = "packs.log"
+	snapshotFile = "snapshot.json"
+	ordersFile   = "orders.log"
+)
+
+// operations recorded in the log, one per Storage method changing the packs.
+const (
+	opAdd       = "add"
+	opRemove    = "remove"
+	opRemoveAll = "remove_all"
+	opReplace   = "replace"
+	opReserve   = "reserve"
+	opLevels    = "levels"
+	opRollback  = "rollback"
+	opSchedule  = "schedule"
+	opCancel    = "cancel"
+	opApply     = "apply"
+)
+
+// record is a single change of the log, Seq grows by one with every change.
+type record struct {
+	Seq     uint64          `json:"seq"`
+	Op      string          `json:"op"`
+	Packs   []storage.Pack  `json:"packs,omitempty"`
+	Size    int             `json:"size,omitempty"`
+	Reserve map[int]int     `json:"reserve,omitempty"`
+	Levels  []storage.Level `json:"levels,omitempty"`
+	Target  int             `json:"target,omitempty"`
+	From    *time.Time      `json:"from,omitempty"`
+	// At & Actor stamp the version recorded by the change.
+	At    time.Time `json:"at"`
+	Actor string    `json:"actor,omitempty"`
+	// Version is only checked when the change is made, a replayed replace has none and is applied as is.
+	Version *int `json:"-"`
+}
+
+// apply replays the change on state.
+func (r record) apply(ctx context.Context, state *memory.MemDB) error {
+	switch r.Op {
+	case opAdd:
+		return state.AddPacks(ctx, r.Packs)
+	case opRemove:
+		return state.RemovePack(ctx, r.Size)
+	case opRemoveAll:
+		return state.RemovePacks(ctx)
+	case opReplace:
+		version := storage.AnyVersion
+		if r.Version != nil {
+			version = *r.Version
+		}
+
+		return state.ReplacePacks(ctx, r.Packs, version)
+	case opReserve:
+		return state.ReservePacks(ctx, r.Reserve)
+	case opLevels:
+		return state.SetLevels(ctx, r.Levels)
+	case opRollback:
+		return state.Rollback(ctx, r.Target)
+	case opSchedule:
+		if r.From == nil {
+			return errors.New("schedule without an effective time")
+		}
+
+		_, err := state.SchedulePacks(ctx, r.Packs, *r.From)
+		return err
+	case opCancel:
+		return state.CancelSchedule(ctx, r.Target)
+	case opApply:
+		_, err := state.ApplySchedules(ctx)
+		return err
+	default:
+		return fmt.Errorf("unknown operation %q", r.Op)
+	}
+}
+
+// clock stamps the version recorded by the change with its time.
+func (r record) clock() time.Time {
+	return r.At
+}
+
+// encode returns the log line of the record.
+func (r record) encode() ([]byte, error) {
+	return encodeLine(r)
+}
+
+// encodeLine returns the log line of v: the crc32 of the JSON, a space, the JSON and a new line.
+// The checksum tells a line torn by a crash apart from a complete one.
+func encodeLine(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	line := fmt.Appendf(nil, "%08x ", crc32.ChecksumIEEE(data))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+// decodeLine parses a log line without its new line into v, it reports false for a damaged line.
+func decodeLine(line []byte, v any) bool {
+	checksum, data, ok := bytes.Cut(line, []byte{' '})
+	if !ok {
+		return false
+	}
+
+	sum, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil || uint32(sum) != crc32.ChecksumIEEE(data) {
+		return false
+	}
+
+	return json.Unmarshal(data, v) == nil
+}
+
+// scanLog calls fn with every line of the log without its new line, fn reports false for a damaged line.
+// It returns the size of the valid part of the log. A damaged line at the end of the log was torn
+// by a crash and is left out of the size, anywhere else the log is corrupted.
+func scanLog(data []byte, fn func(line []byte) (bool, error)) (int, error) {
+	for offset := 0; offset < len(data); {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			return offset, nil
+		}
+
+		ok, err := fn(data[offset : offset+end])
+		if err != nil {
+			return 0, err
+		}
+
+		if !ok {
+			if offset+end+1 < len(data) {
+				return 0, fmt.Errorf("the log is corrupted at offset %d", offset)
+			}
+
+			return offset, nil
+		}
+		offset += end + 1
+	}
+
+	return len(data), nil
+}
+
+// replay applies the records of the log after the given sequence on state.
+// It returns the last sequence, the number of records applied and the size of the valid part of the log.
+func replay(data []byte, state *memory.MemDB, after uint64) (uint64, int, int, error) {
+	seq, applied := after, 0
+	size, err := scanLog(data, func(line []byte) (bool, error) {
+		var r record
+		if !decodeLine(line, &r) {
+			return false, nil
+		}
+
+		// the record was already compacted into the snapshot.
+		if r.Seq <= seq {
+			return true, nil
+		}
+
+		state.SetClock(r.clock)
+		if err := r.apply(storage.WithActor(context.Background(), r.Actor), state); err != nil {
+			return true, fmt.Errorf("replaying record %d: %w", r.Seq, err)
+		}
+
+		seq = r.Seq
+		applied++
+		return true, nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	return seq, applied, size, nil
+}
+
+// snapshot is the compacted state of the log up to Seq.
+type snapshot struct {
+	Seq uint64 `json:"seq"`
+	memory.State
+}
+
+// loadSnapshot returns the state stored in the snapshot of dir and its sequence,
+// an empty state when there is no snapshot yet.
+func loadSnapshot(dir string) (*memory.MemDB, uint64, error) {
+	data, err := os.ReadFile(filepath.Join(dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return memory.NewMemDB(), 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, 0, fmt.Errorf("reading the snapshot: %w", err)
+	}
+
+	// the packs & levels are validated again, the history is restored as is.
+	for _, pack := range snap.Packs {
+		if err := pack.Validate(); err != nil {
+			return nil, 0, fmt.Errorf("reading the snapshot: %w", err)
+		}
+	}
+
+	if err := storage.ValidateLevels(snap.Levels); err != nil {
+		return nil, 0, fmt.Errorf("reading the snapshot: %w", err)
+	}
+
+	return memory.Restore(snap.State), snap.Seq, nil
+}
+
+// writeSnapshot atomically replaces the snapshot of dir, it is synced before it returns.
+func writeSnapshot(dir string, snap snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	// the new snapshot is written aside and renamed, so a crash leaves either the old or the new one.
+	tmp := filepath.Join(dir, snapshotFile+".tmp")
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+
+	return syncDir(dir)
+}
+
+// openLog opens the log at path for appending, only its first size bytes out of length are valid.
+func openLog(path string, size, length int) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop the torn tail, so that new lines follow the last complete one.
+	if size < length {
+		err = f.Truncate(int64(size))
+		if err == nil {
+			err = f.Sync()
+		}
+	}
+	if err == nil {
+		err = syncDir(filepath.Dir(path))
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// syncDir makes the files created or renamed in dir durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
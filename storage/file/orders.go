@@ -0,0 +1,143 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"sync"
+	"time"
+)
+
+// OrderDB keeps the orders in a log of the data directory, an order is synced to disk before it is returned.
+// Orders never change, so the log is only appended to and never compacted.
+// On startup it is read back, an order torn by a crash at the end of the log is dropped.
+type OrderDB struct {
+	mu     sync.RWMutex
+	log    *os.File
+	size   int64
+	orders []storage.Order
+	now    func() time.Time
+}
+
+// NewOrderDB opens the orders kept in dir, the directory is created when missing.
+func NewOrderDB(dir string) (*OrderDB, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, ordersFile)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var orders []storage.Order
+	size, err := scanLog(data, func(line []byte) (bool, error) {
+		var order storage.Order
+		if !decodeLine(line, &order) {
+			return false, nil
+		}
+
+		if order.ID != len(orders)+1 {
+			return true, fmt.Errorf("order %d is out of sequence", order.ID)
+		}
+
+		orders = append(orders, order)
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openLog(path, size, len(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return &OrderDB{log: f, size: int64(size), orders: orders, now: time.Now}, nil
+}
+
+// SetClock replaces the clock stamping the orders, time.Now by default.
+func (db *OrderDB) SetClock(now func() time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.now = now
+}
+
+// AddOrder appends a new order with the next ID to the log.
+func (db *OrderDB) AddOrder(ctx context.Context, order storage.Order) (storage.Order, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// an order waiting for the lock may have been cancelled in the meantime.
+	if err := ctx.Err(); err != nil {
+		return storage.Order{}, err
+	}
+
+	order = storage.CopyOrder(order)
+	order.ID = len(db.orders) + 1
+	order.CreatedAt = db.now().UTC()
+
+	line, err := encodeLine(order)
+	if err != nil {
+		return storage.Order{}, err
+	}
+
+	_, err = db.log.Write(line)
+	if err == nil {
+		err = db.log.Sync()
+	}
+	if err != nil {
+		// a partial order would corrupt the log once the next one is written after it.
+		_ = db.log.Truncate(db.size)
+		return storage.Order{}, err
+	}
+
+	db.size += int64(len(line))
+	db.orders = append(db.orders, order)
+	return storage.CopyOrder(order), nil
+}
+
+func (db *OrderDB) GetOrder(ctx context.Context, id int) (storage.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Order{}, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// orders are numbered from 1 without gaps.
+	if id < 1 || id > len(db.orders) {
+		return storage.Order{}, storage.Errorf(storage.ErrNotFound, "order %d not found", id)
+	}
+
+	return storage.CopyOrder(db.orders[id-1]), nil
+}
+
+// ListOrders returns a page of the orders matching the filter, newest first.
+func (db *OrderDB) ListOrders(ctx context.Context, filter storage.OrderFilter) ([]storage.Order, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	page, total := storage.FilterOrders(db.orders, filter)
+	for i, order := range page {
+		page[i] = storage.CopyOrder(order)
+	}
+
+	return page, total, nil
+}
+
+// Close closes the log, orders can't be added afterwards.
+func (db *OrderDB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.log.Close()
+}
@@ -0,0 +1,122 @@
+package file
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"reparttask/storage"
+	"reparttask/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func openOrders(t *testing.T, dir string) *OrderDB {
+	t.Helper()
+
+	db, err := NewOrderDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOrderDBConformance(t *testing.T) {
+	storagetest.RunOrders(t, func(t *testing.T) storage.OrderStore { return openOrders(t, t.TempDir()) })
+}
+
+func TestOrderDB(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openOrders(t, dir)
+	db.SetClock(func() time.Time { return time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC) })
+
+	var stored []storage.Order
+	for _, quantity := range []int{250, 500} {
+		order, err := db.AddOrder(ctx, storage.Order{
+			SKU:      storage.DefaultSKU,
+			Quantity: quantity,
+			Params:   map[string]string{"objective": "min-packs"},
+			Version:  3,
+			Result:   json.RawMessage(fmt.Sprintf(`{"packs":[{"size":%d,"quantity":1}]}`, quantity)),
+		})
+		assert.NoError(t, err)
+		stored = append(stored, order)
+	}
+	assert.NoError(t, db.Close())
+
+	// the orders survive a restart, new ones follow them.
+	db = openOrders(t, dir)
+	orders, total, err := db.ListOrders(ctx, storage.OrderFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, []storage.Order{stored[1], stored[0]}, orders)
+
+	order, err := db.AddOrder(ctx, storage.Order{SKU: storage.DefaultSKU, Quantity: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, order.ID)
+}
+
+func TestOrderDBTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openOrders(t, dir)
+	_, err := db.AddOrder(ctx, storage.Order{SKU: storage.DefaultSKU, Quantity: 250})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	path := filepath.Join(dir, ordersFile)
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := encodeLine(storage.Order{ID: 2, SKU: storage.DefaultSKU, Quantity: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, append(log, line[:len(line)/2]...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the torn order is dropped and its ID given to the next one.
+	db = openOrders(t, dir)
+	order, err := db.AddOrder(ctx, storage.Order{SKU: storage.DefaultSKU, Quantity: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, order.ID)
+	assert.NoError(t, db.Close())
+
+	db = openOrders(t, dir)
+	order, err = db.GetOrder(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1000, order.Quantity)
+}
+
+func TestOrderDBCorrupted(t *testing.T) {
+	dir := t.TempDir()
+
+	db := openOrders(t, dir)
+	for _, quantity := range []int{250, 500} {
+		_, err := db.AddOrder(ctx, storage.Order{SKU: storage.DefaultSKU, Quantity: quantity})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, db.Close())
+
+	path := filepath.Join(dir, ordersFile)
+	log, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log[3] ^= 0xff
+	if err := os.WriteFile(path, log, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewOrderDB(dir)
+	assert.EqualError(t, err, "the log is corrupted at offset 0")
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"time"
+)
+
+// Changes recorded in the history of the pack set.
+const (
+	ChangeAdd       = "add"
+	ChangeRemove    = "remove"
+	ChangeRemoveAll = "remove_all"
+	ChangeReplace   = "replace"
+	ChangeRollback  = "rollback"
+	ChangeSchedule  = "schedule"
+)
+
+// AnyVersion replaces the packs whatever their version, see Storage.ReplacePacks.
+const AnyVersion = -1
+
+// VersionConflict returns the ErrConflict error of a change expecting another version than current.
+func VersionConflict(current int) error {
+	return Errorf(ErrConflict, "packs were changed, the current version is %d", current)
+}
+
+// PackSet is an immutable version of the pack set, every change of the packs records a new one.
+// Stock reservations are not changes of the pack set, so they don't record a version.
+type PackSet struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor,omitempty"`
+	Change    string    `json:"change"`
+	// Source is the version restored by a rollback.
+	Source int `json:"source,omitempty"`
+	// Schedule is the scheduled change applied by the version.
+	Schedule int    `json:"schedule,omitempty"`
+	Diff     Diff   `json:"diff"`
+	Packs    []Pack `json:"packs"`
+}
+
+// Diff lists the packs added, removed and changed by a version, changed packs hold their new values.
+type Diff struct {
+	Added   []Pack `json:"added,omitempty"`
+	Removed []Pack `json:"removed,omitempty"`
+	Changed []Pack `json:"changed,omitempty"`
+}
+
+// Empty reports whether the diff has no change.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// NewDiff returns the changes from the before to the after packs, both sorted by size.
+func NewDiff(before, after []Pack) Diff {
+	var d Diff
+	i, j := 0, 0
+	for i < len(before) || j < len(after) {
+		switch {
+		case j == len(after) || (i < len(before) && before[i].Size < after[j].Size):
+			d.Removed = append(d.Removed, before[i])
+			i++
+		case i == len(before) || after[j].Size < before[i].Size:
+			d.Added = append(d.Added, after[j])
+			j++
+		default:
+			// the stock & dimensions are pointers, so the values are compared.
+			if !reflect.DeepEqual(before[i], after[j]) {
+				d.Changed = append(d.Changed, after[j])
+			}
+			i++
+			j++
+		}
+	}
+
+	return d
+}
+
+type actorKey struct{}
+
+// WithActor returns a context recording actor as the author of the changes made with it.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the author of the changes made with ctx, empty when unknown.
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
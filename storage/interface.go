@@ -1,8 +1,51 @@
 package storage
 
+import (
+	"context"
+	"time"
+)
+
+// Storage keeps the packs and the packaging hierarchy of a product.
+// Every method stops early and returns the context error once ctx is done,
+// failures are reported with the sentinel errors, eg. ErrNotFound for an unknown size.
 type Storage interface {
-	AddPacks(sizes []int) error
-	RemovePack(size int) error
-	RemovePacks()
-	GetPacks() []int
+	// AddPacks adds new pack sizes. For sizes that already exist, the fields set in the pack replace
+	// the stored ones and the zero ones are kept, ReplacePacks resets them.
+	AddPacks(ctx context.Context, packs []Pack) error
+	RemovePack(ctx context.Context, size int) error
+	RemovePacks(ctx context.Context) error
+	// ReplacePacks atomically replaces every pack with the given ones. Unless version is AnyVersion
+	// it must be the latest version of the history, 0 before the first one, otherwise ErrConflict
+	// is returned and nothing changes.
+	ReplacePacks(ctx context.Context, packs []Pack, version int) error
+	// GetPacks returns the packs sorted by size, the result must be treated as read-only.
+	GetPacks(ctx context.Context) ([]Pack, error)
+	// ReservePacks atomically takes the pack size => count packs out of stock,
+	// either all of them are reserved or none.
+	ReservePacks(ctx context.Context, packs map[int]int) error
+	// SetLevels replaces the packaging hierarchy above the packs, ordered from the packs outwards.
+	SetLevels(ctx context.Context, levels []Level) error
+	GetLevels(ctx context.Context) ([]Level, error)
+	// History returns every version of the packs oldest first, the result must be treated as read-only.
+	// Versions are numbered from 1, a change that leaves the packs as they are records none.
+	History(ctx context.Context) ([]PackSet, error)
+	// PackSet returns a version of the packs, ErrNotFound when it doesn't exist.
+	PackSet(ctx context.Context, version int) (PackSet, error)
+	// Rollback restores the packs of a version as a new version, the stock of sizes still stored is kept.
+	Rollback(ctx context.Context, version int) error
+	// SchedulePacks schedules the packs to replace every pack from the given instant,
+	// which must be in the future, otherwise ErrInvalid is returned.
+	SchedulePacks(ctx context.Context, packs []Pack, from time.Time) (Schedule, error)
+	// Schedules returns the scheduled changes not applied yet, by effective time.
+	Schedules(ctx context.Context) ([]Schedule, error)
+	// CancelSchedule drops a scheduled change not applied yet, ErrNotFound when there is none.
+	CancelSchedule(ctx context.Context, id int) error
+	// ApplySchedules applies the scheduled changes that are due as new versions stamped with their effective time,
+	// it returns how many were applied. Every other change applies them first, so it is never overwritten by them.
+	ApplySchedules(ctx context.Context) (int, error)
+	// ActivePackSet returns the version of the packs active at the given instant, or now when it is zero.
+	// From the latest version on, it holds the stored packs with their current stock, before it the version
+	// then active as it was recorded. A due scheduled change is active even before it is applied, it has
+	// no version number yet. The packs must be treated as read-only.
+	ActivePackSet(ctx context.Context, at time.Time) (PackSet, error)
 }
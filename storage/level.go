@@ -0,0 +1,45 @@
+package storage
+
+// Level is a packaging level above the packs, like cartons or pallets.
+// Capacities are the sizes available at this level, in units of the level below.
+type Level struct {
+	Name       string `json:"name"`
+	Capacities []int  `json:"capacities"`
+}
+
+// ValidateLevels checks a packaging hierarchy, levels are ordered from the packs outwards.
+func ValidateLevels(levels []Level) error {
+	names := map[string]bool{}
+	for _, level := range levels {
+		if level.Name == "" {
+			return Errorf(ErrInvalid, "level name must not be empty")
+		}
+
+		if names[level.Name] {
+			return Errorf(ErrInvalid, "level %q is defined twice", level.Name)
+		}
+		names[level.Name] = true
+
+		if len(level.Capacities) == 0 {
+			return Errorf(ErrInvalid, "level %q must have at least one capacity", level.Name)
+		}
+
+		for _, capacity := range level.Capacities {
+			if capacity <= 0 {
+				return Errorf(ErrInvalid, "level %q capacity must be positive %d", level.Name, capacity)
+			}
+		}
+	}
+
+	return nil
+}
+
+// CopyLevels returns a copy of levels that doesn't share the capacities.
+func CopyLevels(levels []Level) []Level {
+	copied := make([]Level, len(levels))
+	for i, level := range levels {
+		copied[i] = Level{Name: level.Name, Capacities: append([]int{}, level.Capacities...)}
+	}
+
+	return copied
+}
@@ -1,59 +1,599 @@
 package memory
 
 import (
+	"context"
 	"fmt"
+	"reparttask/storage"
+	"sort"
+	"sync"
+	"time"
 )
 
+// MemDB keeps the packs in memory, it is safe for concurrent use.
+// It never blocks, so the context is only checked when a call starts.
+// Packs are stored in a set keyed by size, every change publishes a new sorted
+// snapshot instead of updating the previous one, so readers never see a partial change.
+// Every change of the packs also records a version in the history, stamped by the clock,
+// which also tells when the scheduled changes are due. Every change applies the due ones first.
 type MemDB struct {
-	data []int
+	mu           sync.RWMutex
+	packs        map[int]storage.Pack
+	snapshot     []storage.Pack
+	levels       []storage.Level
+	history      []storage.PackSet
+	schedules    []storage.Schedule
+	lastSchedule int
+	now          func() time.Time
 }
 
 func NewMemDB() *MemDB {
-	return &MemDB{}
+	return &MemDB{packs: map[int]storage.Pack{}, snapshot: []storage.Pack{}, now: time.Now}
 }
 
-func (db *MemDB) AddPacks(sizes []int) error {
-	// validate & remove duplicates, if exist.
-	existing := db.convertToMap()
-	var valid []int
-	for _, size := range sizes {
-		if size <= 0 {
-			return fmt.Errorf("pack size must be positive %d", size)
+// State is everything stored in a MemDB, it is used to save and restore it.
+type State struct {
+	Packs   []storage.Pack    `json:"packs"`
+	Levels  []storage.Level   `json:"levels"`
+	History []storage.PackSet `json:"history,omitempty"`
+	// Schedules are the changes not applied yet, LastSchedule the ID of the last one scheduled.
+	Schedules    []storage.Schedule `json:"schedules,omitempty"`
+	LastSchedule int                `json:"last_schedule,omitempty"`
+}
+
+// Restore returns a MemDB holding state, as returned by State.
+func Restore(state State) *MemDB {
+	db := NewMemDB()
+	db.store(state.Packs)
+	db.publish()
+	db.levels = storage.CopyLevels(state.Levels)
+	db.history = state.History
+	db.schedules = state.Schedules
+	db.lastSchedule = state.LastSchedule
+	return db
+}
+
+// State returns the stored packs, levels, history and schedules, they must be treated as read-only.
+func (db *MemDB) State() State {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return State{
+		Packs:        db.snapshot,
+		Levels:       storage.CopyLevels(db.levels),
+		History:      db.history,
+		Schedules:    db.schedules,
+		LastSchedule: db.lastSchedule,
+	}
+}
+
+// Clone returns a copy of the db that can be changed without affecting it.
+func (db *MemDB) Clone() *MemDB {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	packs := make(map[int]storage.Pack, len(db.packs))
+	for size, pack := range db.packs {
+		packs[size] = pack
+	}
+
+	return &MemDB{
+		packs:    packs,
+		snapshot: db.snapshot,
+		levels:   storage.CopyLevels(db.levels),
+		// versions are never changed, the clip makes the clone append to its own array.
+		history: db.history[:len(db.history):len(db.history)],
+		// schedules are replaced rather than changed, like the snapshot.
+		schedules:    db.schedules,
+		lastSchedule: db.lastSchedule,
+		now:          db.now,
+	}
+}
+
+// SetClock replaces the clock stamping the versions and telling when schedules are due, time.Now by default.
+func (db *MemDB) SetClock(now func() time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.now = now
+}
+
+// AddPacks adds new pack sizes, the fields set for sizes that already exist replace the stored ones.
+func (db *MemDB) AddPacks(ctx context.Context, packs []storage.Pack) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// validate everything first, so that an invalid pack doesn't leave a partial update.
+	for _, pack := range packs {
+		if err := pack.Validate(); err != nil {
+			return err
 		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
 
-		if _, ok := existing[size]; !ok {
-			valid = append(valid, size)
+	db.applyDue()
+
+	before := db.snapshot
+	for _, pack := range packs {
+		if stored, ok := db.packs[pack.Size]; ok {
+			pack = merge(stored, pack)
+		}
+
+		db.store([]storage.Pack{pack})
+	}
+	db.publish()
+	db.record(storage.PackSet{Actor: storage.Actor(ctx), Change: storage.ChangeAdd}, before)
+	return nil
+}
+
+// ReplacePacks replaces every pack, unless version is AnyVersion it must be the latest version.
+func (db *MemDB) ReplacePacks(ctx context.Context, packs []storage.Pack, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for _, pack := range packs {
+		if err := pack.Validate(); err != nil {
+			return err
 		}
 	}
 
-	db.data = append(db.data, valid...)
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	if current := len(db.history); version != storage.AnyVersion && version != current {
+		return storage.VersionConflict(current)
+	}
+
+	before := db.snapshot
+	db.packs = map[int]storage.Pack{}
+	db.store(packs)
+	db.publish()
+	db.record(storage.PackSet{Actor: storage.Actor(ctx), Change: storage.ChangeReplace}, before)
 	return nil
 }
 
-func (db *MemDB) RemovePack(size int) error {
-	for i := 0; i < len(db.data); i++ {
-		if db.data[i] == size {
-			db.data = append(db.data[:i], db.data[i+1:]...)
+func (db *MemDB) RemovePack(ctx context.Context, size int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	if _, ok := db.packs[size]; !ok {
+		return storage.Errorf(storage.ErrNotFound, "size %d not found", size)
+	}
+
+	before := db.snapshot
+	delete(db.packs, size)
+	db.publish()
+	db.record(storage.PackSet{Actor: storage.Actor(ctx), Change: storage.ChangeRemove}, before)
+	return nil
+}
+
+// GetPacks returns a snapshot of the stored packs sorted by size.
+// The snapshot is shared between callers and is never changed, so it must be treated as read-only.
+func (db *MemDB) GetPacks(ctx context.Context) ([]storage.Pack, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.snapshot, nil
+}
+
+func (db *MemDB) RemovePacks(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	before := db.snapshot
+	db.packs = map[int]storage.Pack{}
+	db.publish()
+	db.record(storage.PackSet{Actor: storage.Actor(ctx), Change: storage.ChangeRemoveAll}, before)
+	return nil
+}
+
+// ReservePacks takes the given packs out of stock, sizes with unlimited stock are left as they are.
+func (db *MemDB) ReservePacks(ctx context.Context, packs map[int]int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	// check every size before changing anything, so a failed reservation has no effect.
+	for size, count := range packs {
+		pack, ok := db.packs[size]
+		if !ok {
+			return storage.Errorf(storage.ErrNotFound, "size %d not found", size)
+		}
+
+		if stock := pack.Stock; stock != nil && *stock < count {
+			return fmt.Errorf("%w: size %d has %d left, %d requested", storage.ErrInsufficientStock, size, *stock, count)
+		}
+	}
+
+	changed := false
+	for size, count := range packs {
+		pack := db.packs[size]
+		if pack.Stock == nil {
+			continue
+		}
+
+		// replace the pointer rather than updating it, published snapshots share it.
+		left := *pack.Stock - count
+		pack.Stock = &left
+		db.packs[size] = pack
+		changed = true
+	}
+
+	if changed {
+		db.publish()
+	}
+
+	return nil
+}
+
+// SetLevels replaces the packaging hierarchy, an empty one leaves only the packs.
+func (db *MemDB) SetLevels(ctx context.Context, levels []storage.Level) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := storage.ValidateLevels(levels); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	db.levels = storage.CopyLevels(levels)
+	return nil
+}
+
+// GetLevels returns a copy of the packaging hierarchy.
+func (db *MemDB) GetLevels(ctx context.Context) ([]storage.Level, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return storage.CopyLevels(db.levels), nil
+}
+
+// History returns every version of the packs, oldest first.
+// The versions are shared between callers and are never changed, so they must be treated as read-only.
+func (db *MemDB) History(ctx context.Context) ([]storage.PackSet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.history, nil
+}
+
+// PackSet returns a version of the packs.
+func (db *MemDB) PackSet(ctx context.Context, version int) (storage.PackSet, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.PackSet{}, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.version(version)
+}
+
+// Rollback restores the packs of a version as a new version.
+// Reservations are not versioned, so sizes that are still stored keep their current stock.
+func (db *MemDB) Rollback(ctx context.Context, version int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	set, err := db.version(version)
+	if err != nil {
+		return err
+	}
+
+	packs := db.withStock(set.Packs)
+	before := db.snapshot
+	db.packs = map[int]storage.Pack{}
+	db.store(packs)
+	db.publish()
+	db.record(storage.PackSet{Actor: storage.Actor(ctx), Change: storage.ChangeRollback, Source: version}, before)
+	return nil
+}
+
+// SchedulePacks schedules the packs to replace every pack from the given instant.
+func (db *MemDB) SchedulePacks(ctx context.Context, packs []storage.Pack, from time.Time) (storage.Schedule, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Schedule{}, err
+	}
+
+	for _, pack := range packs {
+		if err := pack.Validate(); err != nil {
+			return storage.Schedule{}, err
+		}
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	now := db.now()
+	if !from.After(now) {
+		return storage.Schedule{}, storage.Errorf(storage.ErrInvalid, "the effective time must be in the future")
+	}
+
+	db.lastSchedule++
+	schedule := storage.Schedule{
+		ID:            db.lastSchedule,
+		EffectiveFrom: from.UTC(),
+		CreatedAt:     now.UTC(),
+		Actor:         storage.Actor(ctx),
+		Packs:         normalize(packs),
+	}
+
+	// a new slice is published, readers may still hold the previous one.
+	schedules := append(append([]storage.Schedule{}, db.schedules...), schedule)
+	sort.SliceStable(schedules, func(i, j int) bool { return schedules[i].EffectiveFrom.Before(schedules[j].EffectiveFrom) })
+	db.schedules = schedules
+	return schedule, nil
+}
+
+// Schedules returns the scheduled changes not applied yet, by effective time.
+// They are shared between callers, so they must be treated as read-only.
+func (db *MemDB) Schedules(ctx context.Context) ([]storage.Schedule, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.schedules, nil
+}
+
+func (db *MemDB) CancelSchedule(ctx context.Context, id int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.applyDue()
+
+	for i, schedule := range db.schedules {
+		if schedule.ID == id {
+			db.schedules = append(append([]storage.Schedule{}, db.schedules[:i]...), db.schedules[i+1:]...)
 			return nil
 		}
 	}
 
-	return fmt.Errorf("size %d not found", size)
+	return storage.Errorf(storage.ErrNotFound, "schedule %d not found", id)
+}
+
+// ApplySchedules applies the due scheduled changes in order, each one records a version by its author.
+// Every other change applies them first as well, so a change made once a schedule is due is never overwritten by it.
+func (db *MemDB) ApplySchedules(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.applyDue(), nil
+}
+
+// ActivePackSet returns the version of the packs active at the given instant, or now when it is zero.
+// The packs are shared between callers, so they must be treated as read-only.
+func (db *MemDB) ActivePackSet(ctx context.Context, at time.Time) (storage.PackSet, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.PackSet{}, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if at.IsZero() {
+		at = db.now()
+	}
+
+	// the last due schedule replaces every pack, whatever the versions before it.
+	due := sort.Search(len(db.schedules), func(i int) bool { return db.schedules[i].EffectiveFrom.After(at) })
+	if due > 0 {
+		schedule := db.schedules[due-1]
+		return storage.PackSet{
+			CreatedAt: schedule.EffectiveFrom,
+			Actor:     schedule.Actor,
+			Change:    storage.ChangeSchedule,
+			Schedule:  schedule.ID,
+			Packs:     db.withStock(schedule.Packs),
+		}, nil
+	}
+
+	if len(db.history) == 0 {
+		return storage.PackSet{Packs: db.snapshot}, nil
+	}
+
+	if latest := db.history[len(db.history)-1]; !at.Before(latest.CreatedAt) {
+		latest.Packs = db.snapshot
+		return latest, nil
+	}
+
+	// versions are recorded in time order, the active one is the last created by then.
+	i := sort.Search(len(db.history), func(i int) bool { return db.history[i].CreatedAt.After(at) })
+	if i == 0 {
+		return storage.PackSet{Packs: []storage.Pack{}}, nil
+	}
+
+	return db.history[i-1], nil
+}
+
+// applyDue applies the due scheduled changes in order, it must be called with the lock held.
+// The versions are stamped with the effective time of their schedule, which is when orders started to use them.
+func (db *MemDB) applyDue() int {
+	now := db.now()
+	applied := 0
+	for len(db.schedules) > 0 && !db.schedules[0].EffectiveFrom.After(now) {
+		schedule := db.schedules[0]
+		db.schedules = db.schedules[1:]
+
+		packs := db.withStock(schedule.Packs)
+		before := db.snapshot
+		db.packs = map[int]storage.Pack{}
+		db.store(packs)
+		db.publish()
+		db.record(storage.PackSet{
+			CreatedAt: schedule.EffectiveFrom,
+			Actor:     schedule.Actor,
+			Change:    storage.ChangeSchedule,
+			Schedule:  schedule.ID,
+		}, before)
+		applied++
+	}
+
+	return applied
+}
+
+// version returns a version of the history, it must be called with the lock held.
+func (db *MemDB) version(version int) (storage.PackSet, error) {
+	// versions are numbered from 1 without gaps.
+	if version < 1 || version > len(db.history) {
+		return storage.PackSet{}, storage.Errorf(storage.ErrNotFound, "version %d not found", version)
+	}
+
+	return db.history[version-1], nil
+}
+
+// withStock returns a copy of the packs where the sizes that are still stored keep their current stock,
+// reservations are not versioned. It must be called with the lock held.
+func (db *MemDB) withStock(packs []storage.Pack) []storage.Pack {
+	merged := make([]storage.Pack, len(packs))
+	for i, pack := range packs {
+		if current, ok := db.packs[pack.Size]; ok {
+			pack.Stock = current.Stock
+		}
+
+		merged[i] = pack
+	}
+
+	return merged
 }
 
-func (db *MemDB) GetPacks() []int {
-	return db.data
+// record adds the published snapshot to the history as set when it differs from before,
+// stamped now unless set is already stamped. It must be called with the lock held.
+func (db *MemDB) record(set storage.PackSet, before []storage.Pack) {
+	set.Diff = storage.NewDiff(before, db.snapshot)
+	if set.Diff.Empty() {
+		return
+	}
+
+	if set.CreatedAt.IsZero() {
+		set.CreatedAt = db.now()
+	}
+
+	set.Version = len(db.history) + 1
+	set.CreatedAt = set.CreatedAt.UTC()
+	set.Packs = db.snapshot
+	db.history = append(db.history, set)
 }
 
-func (db *MemDB) RemovePacks() {
-	db.data = []int{}
+// store adds the packs to the set, it must be called with the lock held.
+func (db *MemDB) store(packs []storage.Pack) {
+	for _, pack := range packs {
+		// keep our own copy of the cost, stock & dimensions, the caller may reuse the pointers.
+		if pack.Cost != nil {
+			cost := *pack.Cost
+			pack.Cost = &cost
+		}
+
+		if pack.Stock != nil {
+			stock := *pack.Stock
+			pack.Stock = &stock
+		}
+
+		if pack.Dimensions != nil {
+			dimensions := *pack.Dimensions
+			pack.Dimensions = &dimensions
+		}
+
+		db.packs[pack.Size] = pack
+	}
 }
 
-func (db *MemDB) convertToMap() map[int]int {
-	result := map[int]int{}
-	for _, size := range db.data {
-		result[size] = size
+// publish replaces the snapshot with the sorted packs of the set, it must be called with the lock held.
+func (db *MemDB) publish() {
+	snapshot := make([]storage.Pack, 0, len(db.packs))
+	for _, pack := range db.packs {
+		snapshot = append(snapshot, pack)
 	}
 
-	return result
+	sort.Slice(snapshot, func(i, j int) bool { return snapshot[i].Size < snapshot[j].Size })
+	db.snapshot = snapshot
+}
+
+// merge returns the stored pack updated with the fields set in pack, the zero ones keep their stored value.
+func merge(stored, pack storage.Pack) storage.Pack {
+	if pack.Cost == nil {
+		pack.Cost = stored.Cost
+	}
+	if pack.Stock == nil {
+		pack.Stock = stored.Stock
+	}
+	if pack.Name == "" {
+		pack.Name = stored.Name
+	}
+	if pack.Barcode == "" {
+		pack.Barcode = stored.Barcode
+	}
+	if pack.Dimensions == nil {
+		pack.Dimensions = stored.Dimensions
+	}
+	if pack.TareWeight == 0 {
+		pack.TareWeight = stored.TareWeight
+	}
+
+	return pack
+}
+
+// normalize returns a sorted copy of the packs, the last pack of a size wins.
+func normalize(packs []storage.Pack) []storage.Pack {
+	set := &MemDB{packs: map[int]storage.Pack{}}
+	set.store(packs)
+	set.publish()
+	return set.snapshot
 }
@@ -0,0 +1,150 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reparttask/storage"
+	"reparttask/storage/storagetest"
+	"sort"
+	"sync"
+	"testing"
+)
+
+var ctx = context.Background()
+
+// packs returns the stored packs, failing the test on error.
+func packs(t *testing.T, db storage.Storage) []storage.Pack {
+	t.Helper()
+
+	packs, err := db.GetPacks(ctx)
+	assert.NoError(t, err)
+	return packs
+}
+
+// levels returns the stored levels, failing the test on error.
+func levels(t *testing.T, db storage.Storage) []storage.Level {
+	t.Helper()
+
+	levels, err := db.GetLevels(ctx)
+	assert.NoError(t, err)
+	return levels
+}
+
+func TestMemDBConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage { return NewMemDB() })
+}
+
+func TestMemDB(t *testing.T) {
+	db := NewMemDB()
+	assert.Equal(t, []storage.Pack{}, packs(t, db))
+
+	// sizes are kept sorted, a size added twice keeps the last pack.
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 1000}, {Size: 250, Cost: cost(5)}, {Size: 500}, {Size: 250, Cost: cost(10)}}))
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(10)}, {Size: 500}, {Size: 1000}}, packs(t, db))
+
+	snapshot := packs(t, db)
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 750}}))
+	assert.NoError(t, db.RemovePack(ctx, 500))
+	assert.Equal(t, []int{250, 750, 1000}, storage.Sizes(packs(t, db)))
+
+	// a snapshot is never changed by later writes.
+	assert.Equal(t, []int{250, 500, 1000}, storage.Sizes(snapshot))
+
+	err := db.RemovePack(ctx, 500)
+	assert.EqualError(t, err, "size 500 not found")
+	assert.True(t, errors.Is(err, storage.ErrNotFound))
+
+	// an invalid pack leaves the stored packs untouched.
+	assert.Error(t, db.AddPacks(ctx, []storage.Pack{{Size: 2000}, {Size: -1}}))
+	assert.Equal(t, []int{250, 750, 1000}, storage.Sizes(packs(t, db)))
+
+	assert.NoError(t, db.RemovePacks(ctx))
+	assert.Empty(t, packs(t, db))
+}
+
+func TestMemDBReservePacks(t *testing.T) {
+	db := NewMemDB()
+	stock := 2
+	assert.NoError(t, db.AddPacks(ctx, []storage.Pack{{Size: 250, Stock: &stock}, {Size: 500}}))
+
+	// the caller's stock pointer is not shared.
+	stock = 100
+
+	snapshot := packs(t, db)
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 1, 500: 10}))
+	assert.Equal(t, 1, *packs(t, db)[0].Stock)
+	assert.Equal(t, 2, *snapshot[0].Stock)
+
+	// a failed reservation has no effect.
+	err := db.ReservePacks(ctx, map[int]int{250: 1, 500: 1, 1000: 1})
+	assert.EqualError(t, err, "size 1000 not found")
+	err = db.ReservePacks(ctx, map[int]int{250: 2})
+	assert.True(t, errors.Is(err, storage.ErrInsufficientStock))
+	assert.Equal(t, 1, *packs(t, db)[0].Stock)
+}
+
+func TestMemDBConcurrent(t *testing.T) {
+	const (
+		workers    = 8
+		iterations = 500
+	)
+
+	db := NewMemDB()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				size := (w*iterations+i)%50 + 1
+				switch i % 6 {
+				case 0, 1:
+					stock := 1000
+					_ = db.AddPacks(ctx, []storage.Pack{{Size: size, Stock: &stock}, {Size: size + 50}})
+				case 2:
+					_ = db.RemovePack(ctx, size)
+				case 3:
+					_ = db.ReservePacks(ctx, map[int]int{size: 1})
+				case 4:
+					_ = db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{size}}})
+					levels(t, db)
+				case 5:
+					if i%60 == 5 {
+						assert.NoError(t, db.RemovePacks(ctx))
+					}
+				}
+
+				// every snapshot must be sorted without duplicates, whatever runs next to it.
+				snapshot := packs(t, db)
+				sizes := storage.Sizes(snapshot)
+				if !sort.IntsAreSorted(sizes) {
+					t.Errorf("packs are not sorted %v", sizes)
+					return
+				}
+
+				for j := 1; j < len(sizes); j++ {
+					if sizes[j] == sizes[j-1] {
+						t.Errorf("size %d is stored twice", sizes[j])
+						return
+					}
+				}
+
+				for _, pack := range snapshot {
+					if pack.Stock != nil {
+						_ = *pack.Stock
+					}
+				}
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
+}
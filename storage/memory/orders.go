@@ -0,0 +1,76 @@
+package memory
+
+import (
+	"context"
+	"reparttask/storage"
+	"sync"
+	"time"
+)
+
+// OrderDB keeps the orders in memory, it is safe for concurrent use.
+type OrderDB struct {
+	mu     sync.RWMutex
+	orders []storage.Order
+	now    func() time.Time
+}
+
+func NewOrderDB() *OrderDB {
+	return &OrderDB{now: time.Now}
+}
+
+// SetClock replaces the clock stamping the orders, time.Now by default.
+func (db *OrderDB) SetClock(now func() time.Time) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.now = now
+}
+
+// AddOrder stores a new order with the next ID.
+func (db *OrderDB) AddOrder(ctx context.Context, order storage.Order) (storage.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Order{}, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	order = storage.CopyOrder(order)
+	order.ID = len(db.orders) + 1
+	order.CreatedAt = db.now().UTC()
+	db.orders = append(db.orders, order)
+	return storage.CopyOrder(order), nil
+}
+
+func (db *OrderDB) GetOrder(ctx context.Context, id int) (storage.Order, error) {
+	if err := ctx.Err(); err != nil {
+		return storage.Order{}, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	// orders are numbered from 1 without gaps.
+	if id < 1 || id > len(db.orders) {
+		return storage.Order{}, storage.Errorf(storage.ErrNotFound, "order %d not found", id)
+	}
+
+	return storage.CopyOrder(db.orders[id-1]), nil
+}
+
+// ListOrders returns a page of the orders matching the filter, newest first.
+func (db *OrderDB) ListOrders(ctx context.Context, filter storage.OrderFilter) ([]storage.Order, int, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	page, total := storage.FilterOrders(db.orders, filter)
+	for i, order := range page {
+		page[i] = storage.CopyOrder(order)
+	}
+
+	return page, total, nil
+}
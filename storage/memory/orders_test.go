@@ -0,0 +1,11 @@
+package memory
+
+import (
+	"reparttask/storage"
+	"reparttask/storage/storagetest"
+	"testing"
+)
+
+func TestOrderDBConformance(t *testing.T) {
+	storagetest.RunOrders(t, func(t *testing.T) storage.OrderStore { return NewOrderDB() })
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Observed wraps a Storage and notifies listeners after every successful
+// change of the pack set through AddPacks, RemovePack, RemovePacks, ReplacePacks, Rollback or ApplySchedules.
+// Stock reservations, levels and schedules don't change the pack set, so they are only reported
+// when they applied a due scheduled change first.
+type Observed struct {
+	Storage
+
+	mu        sync.RWMutex
+	listeners []func()
+}
+
+func NewObserved(db Storage) *Observed {
+	return &Observed{Storage: db}
+}
+
+// OnChange registers a listener, listeners are called synchronously in registration order.
+func (o *Observed) OnChange(fn func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.listeners = append(o.listeners, fn)
+}
+
+func (o *Observed) AddPacks(ctx context.Context, packs []Pack) error {
+	err := o.Storage.AddPacks(ctx, packs)
+	if err == nil {
+		o.notify()
+	}
+
+	return err
+}
+
+func (o *Observed) RemovePack(ctx context.Context, size int) error {
+	err := o.Storage.RemovePack(ctx, size)
+	if err == nil {
+		o.notify()
+	}
+
+	return err
+}
+
+func (o *Observed) RemovePacks(ctx context.Context) error {
+	err := o.Storage.RemovePacks(ctx)
+	if err == nil {
+		o.notify()
+	}
+
+	return err
+}
+
+func (o *Observed) ReplacePacks(ctx context.Context, packs []Pack, version int) error {
+	err := o.Storage.ReplacePacks(ctx, packs, version)
+	if err == nil {
+		o.notify()
+	}
+
+	return err
+}
+
+func (o *Observed) Rollback(ctx context.Context, version int) error {
+	err := o.Storage.Rollback(ctx, version)
+	if err == nil {
+		o.notify()
+	}
+
+	return err
+}
+
+// ApplySchedules only notifies the listeners when a scheduled change was applied.
+func (o *Observed) ApplySchedules(ctx context.Context) (int, error) {
+	applied, err := o.Storage.ApplySchedules(ctx)
+	if err == nil && applied > 0 {
+		o.notify()
+	}
+
+	return applied, err
+}
+
+func (o *Observed) ReservePacks(ctx context.Context, packs map[int]int) error {
+	return o.applying(ctx, func() error { return o.Storage.ReservePacks(ctx, packs) })
+}
+
+func (o *Observed) SetLevels(ctx context.Context, levels []Level) error {
+	return o.applying(ctx, func() error { return o.Storage.SetLevels(ctx, levels) })
+}
+
+func (o *Observed) SchedulePacks(ctx context.Context, packs []Pack, from time.Time) (Schedule, error) {
+	var schedule Schedule
+	err := o.applying(ctx, func() (err error) {
+		schedule, err = o.Storage.SchedulePacks(ctx, packs, from)
+		return err
+	})
+
+	return schedule, err
+}
+
+func (o *Observed) CancelSchedule(ctx context.Context, id int) error {
+	return o.applying(ctx, func() error { return o.Storage.CancelSchedule(ctx, id) })
+}
+
+// applying runs a change that doesn't touch the pack set, the listeners are notified
+// when a due scheduled change was applied before it, which records a new version.
+func (o *Observed) applying(ctx context.Context, change func() error) error {
+	before, err := o.Storage.History(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = change()
+
+	after, _ := o.Storage.History(ctx)
+	if len(after) != len(before) {
+		o.notify()
+	}
+
+	return err
+}
+
+func (o *Observed) notify() {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	for _, fn := range o.listeners {
+		fn()
+	}
+}
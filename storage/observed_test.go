@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type dbMock struct {
+	applied int
+	err     error
+	// due makes the next reservation apply a scheduled change, which records a version.
+	due      bool
+	versions []PackSet
+}
+
+func (db *dbMock) AddPacks(ctx context.Context, packs []Pack) error { return db.err }
+
+func (db *dbMock) RemovePack(ctx context.Context, size int) error { return db.err }
+
+func (db *dbMock) RemovePacks(ctx context.Context) error { return db.err }
+
+func (db *dbMock) ReplacePacks(ctx context.Context, packs []Pack, version int) error {
+	return db.err
+}
+
+func (db *dbMock) GetPacks(ctx context.Context) ([]Pack, error) { return nil, db.err }
+
+func (db *dbMock) ReservePacks(ctx context.Context, packs map[int]int) error {
+	if db.due {
+		db.due = false
+		db.versions = append(db.versions, PackSet{Version: len(db.versions) + 1, Change: ChangeSchedule})
+	}
+
+	return db.err
+}
+
+func (db *dbMock) SetLevels(ctx context.Context, levels []Level) error { return db.err }
+
+func (db *dbMock) GetLevels(ctx context.Context) ([]Level, error) { return nil, db.err }
+
+func (db *dbMock) History(ctx context.Context) ([]PackSet, error) { return db.versions, db.err }
+
+func (db *dbMock) PackSet(ctx context.Context, version int) (PackSet, error) {
+	return PackSet{}, db.err
+}
+
+func (db *dbMock) Rollback(ctx context.Context, version int) error { return db.err }
+
+func (db *dbMock) SchedulePacks(ctx context.Context, packs []Pack, from time.Time) (Schedule, error) {
+	return Schedule{}, db.err
+}
+
+func (db *dbMock) Schedules(ctx context.Context) ([]Schedule, error) { return nil, db.err }
+
+func (db *dbMock) CancelSchedule(ctx context.Context, id int) error { return db.err }
+
+func (db *dbMock) ApplySchedules(ctx context.Context) (int, error) { return db.applied, db.err }
+
+func (db *dbMock) ActivePackSet(ctx context.Context, at time.Time) (PackSet, error) {
+	return PackSet{}, db.err
+}
+
+func TestObserved(t *testing.T) {
+	ctx := context.Background()
+	db := &dbMock{}
+	o := NewObserved(db)
+
+	changes := 0
+	o.OnChange(func() { changes++ })
+
+	assert.NoError(t, o.AddPacks(ctx, []Pack{{Size: 250}}))
+	assert.NoError(t, o.RemovePack(ctx, 250))
+	assert.NoError(t, o.RemovePacks(ctx))
+	assert.NoError(t, o.ReplacePacks(ctx, []Pack{{Size: 500}}, AnyVersion))
+	assert.NoError(t, o.Rollback(ctx, 1))
+	assert.Equal(t, 5, changes)
+
+	// stock reservations don't change the pack set, nor do schedules before they are applied.
+	assert.NoError(t, o.ReservePacks(ctx, map[int]int{250: 1}))
+	_, err := o.SchedulePacks(ctx, []Pack{{Size: 500}}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	_, err = o.ApplySchedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, changes)
+
+	db.applied = 1
+	_, err = o.ApplySchedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, changes)
+
+	// unless they apply a due schedule first.
+	db.due = true
+	assert.NoError(t, o.ReservePacks(ctx, map[int]int{250: 1}))
+	assert.Equal(t, 7, changes)
+
+	// failed changes are not reported.
+	db.err = errors.New("an error has occurred")
+	assert.Error(t, o.AddPacks(ctx, []Pack{{Size: 250}}))
+	assert.Error(t, o.RemovePack(ctx, 250))
+	assert.Error(t, o.RemovePacks(ctx))
+	assert.Error(t, o.ReplacePacks(ctx, []Pack{{Size: 500}}, AnyVersion))
+	assert.Error(t, o.Rollback(ctx, 1))
+	_, err = o.ApplySchedules(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 7, changes)
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// OrderStore keeps the orders placed, orders are never changed once stored.
+// Like Storage, every method returns the context error once ctx is done.
+type OrderStore interface {
+	// AddOrder stores a new order, it returns it with the ID and creation time assigned by the store.
+	// IDs are numbered from 1 in the order the orders are stored.
+	AddOrder(ctx context.Context, order Order) (Order, error)
+	// GetOrder returns an order, ErrNotFound when it doesn't exist.
+	GetOrder(ctx context.Context, id int) (Order, error)
+	// ListOrders returns a page of the orders matching the filter, newest first,
+	// together with the number of matching orders.
+	ListOrders(ctx context.Context, filter OrderFilter) ([]Order, int, error)
+}
+
+// Order is an order as it was calculated: the request, the result and the version of the packs used.
+// The result is encoded by the caller, the store keeps it as it is.
+type Order struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor,omitempty"`
+	SKU       string    `json:"sku"`
+	Quantity  int       `json:"quantity"`
+	// Params are the calculation parameters of the request, eg. the objective or the mode.
+	Params map[string]string `json:"params,omitempty"`
+	// Version is the version of the packs used, 0 for a scheduled change not applied yet.
+	Version int             `json:"version"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// OrderFilter selects the orders listed, zero fields match every order.
+// From is inclusive and To exclusive, Limit 0 returns every order after Offset.
+type OrderFilter struct {
+	SKU    string
+	From   time.Time
+	To     time.Time
+	Offset int
+	Limit  int
+}
+
+// Match reports whether the order is selected by the filter, regardless of the page.
+func (f OrderFilter) Match(order Order) bool {
+	switch {
+	case f.SKU != "" && order.SKU != f.SKU:
+		return false
+	case !f.From.IsZero() && order.CreatedAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !order.CreatedAt.Before(f.To):
+		return false
+	default:
+		return true
+	}
+}
+
+// FilterOrders returns the page of the orders selected by the filter newest first, and the number selected.
+// The orders must be sorted by ID.
+func FilterOrders(orders []Order, filter OrderFilter) ([]Order, int) {
+	page := []Order{}
+	total := 0
+	for i := len(orders) - 1; i >= 0; i-- {
+		if !filter.Match(orders[i]) {
+			continue
+		}
+
+		if total >= filter.Offset && (filter.Limit == 0 || len(page) < filter.Limit) {
+			page = append(page, orders[i])
+		}
+		total++
+	}
+
+	return page, total
+}
+
+// CopyOrder returns a copy of the order that doesn't share the params or the result with it.
+func CopyOrder(order Order) Order {
+	if order.Result != nil {
+		order.Result = append(json.RawMessage{}, order.Result...)
+	}
+
+	if order.Params != nil {
+		params := make(map[string]string, len(order.Params))
+		for k, v := range order.Params {
+			params[k] = v
+		}
+		order.Params = params
+	}
+
+	return order
+}
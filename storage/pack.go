@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// maxNameLength is the longest pack name accepted, in characters.
+const maxNameLength = 100
+
+var barcodePattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// Pack is a packaging size together with the cost of a single pack,
+// expressed in the smallest currency unit, and the number of packs in stock.
+// A nil Cost means the pack has no cost set, a zero one that it is free.
+// A nil Stock means the size is unlimited.
+// The name, barcode, dimensions and tare weight are printed on labels and
+// sent to carriers, they are all optional.
+type Pack struct {
+	Size       int         `json:"size"`
+	Cost       *int64      `json:"cost,omitempty"`
+	Stock      *int        `json:"stock,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	Barcode    string      `json:"barcode,omitempty"`
+	Dimensions *Dimensions `json:"dimensions,omitempty"`
+	// TareWeight is the weight of the empty pack, in grams.
+	TareWeight int `json:"tare_weight,omitempty"`
+}
+
+// Dimensions are the outer dimensions of a pack, in millimetres.
+type Dimensions struct {
+	Length int `json:"length"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Validate checks every field of the pack.
+func (p Pack) Validate() error {
+	if p.Size <= 0 {
+		return Errorf(ErrInvalidSize, "pack size must be positive %d", p.Size)
+	}
+
+	if p.Cost != nil && *p.Cost < 0 {
+		return Errorf(ErrInvalid, "pack cost must not be negative %d", *p.Cost)
+	}
+
+	if p.Stock != nil && *p.Stock < 0 {
+		return Errorf(ErrInvalid, "pack stock must not be negative %d", *p.Stock)
+	}
+
+	if utf8.RuneCountInString(p.Name) > maxNameLength {
+		return Errorf(ErrInvalid, "pack name must be at most %d characters", maxNameLength)
+	}
+
+	if p.Barcode != "" && !barcodePattern.MatchString(p.Barcode) {
+		return Errorf(ErrInvalid, "pack barcode must be 1 to 64 letters, digits or '-' %q", p.Barcode)
+	}
+
+	if d := p.Dimensions; d != nil && (d.Length <= 0 || d.Width <= 0 || d.Height <= 0) {
+		return Errorf(ErrInvalid, "pack dimensions must be positive %dx%dx%d", d.Length, d.Width, d.Height)
+	}
+
+	if p.TareWeight < 0 {
+		return Errorf(ErrInvalid, "pack tare weight must not be negative %d", p.TareWeight)
+	}
+
+	return nil
+}
+
+// Sizes returns the sizes of the given packs, in the same order.
+func Sizes(packs []Pack) []int {
+	sizes := make([]int, len(packs))
+	for i, p := range packs {
+		sizes[i] = p.Size
+	}
+
+	return sizes
+}
+
+// Costs returns the pack size => cost map of the packs with a cost, free ones included,
+// the others are left out.
+func Costs(packs []Pack) map[int]int64 {
+	costs := make(map[int]int64, len(packs))
+	for _, p := range packs {
+		if p.Cost != nil {
+			costs[p.Size] = *p.Cost
+		}
+	}
+
+	return costs
+}
+
+// Stock returns the pack size => available count map of the packs with limited stock.
+func Stock(packs []Pack) map[int]int {
+	stock := map[int]int{}
+	for _, p := range packs {
+		if p.Stock != nil {
+			stock[p.Size] = *p.Stock
+		}
+	}
+
+	return stock
+}
@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestPack_Validate(t *testing.T) {
+	negative := -1
+
+	tests := []struct {
+		name string
+		pack Pack
+		err  error
+	}{
+		{
+			name: "test bare size",
+			pack: Pack{Size: 250},
+		},
+		{
+			name: "test every field",
+			pack: Pack{
+				Size:       250,
+				Cost:       cost(10),
+				Name:       "Small box",
+				Barcode:    "SB-250",
+				Dimensions: &Dimensions{Length: 300, Width: 200, Height: 100},
+				TareWeight: 120,
+			},
+		},
+		{
+			name: "test size 0",
+			pack: Pack{Size: 0},
+			err:  Errorf(ErrInvalidSize, "pack size must be positive 0"),
+		},
+		{
+			name: "test negative cost",
+			pack: Pack{Size: 250, Cost: cost(-1)},
+			err:  Errorf(ErrInvalid, "pack cost must not be negative -1"),
+		},
+		{
+			name: "test negative stock",
+			pack: Pack{Size: 250, Stock: &negative},
+			err:  Errorf(ErrInvalid, "pack stock must not be negative -1"),
+		},
+		{
+			name: "test long name",
+			pack: Pack{Size: 250, Name: strings.Repeat("é", 101)},
+			err:  Errorf(ErrInvalid, "pack name must be at most 100 characters"),
+		},
+		{
+			name: "test invalid barcode",
+			pack: Pack{Size: 250, Barcode: "SB 250"},
+			err:  Errorf(ErrInvalid, `pack barcode must be 1 to 64 letters, digits or '-' "SB 250"`),
+		},
+		{
+			name: "test zero dimension",
+			pack: Pack{Size: 250, Dimensions: &Dimensions{Length: 300, Width: 200}},
+			err:  Errorf(ErrInvalid, "pack dimensions must be positive 300x200x0"),
+		},
+		{
+			name: "test negative tare weight",
+			pack: Pack{Size: 250, TareWeight: -5},
+			err:  Errorf(ErrInvalid, "pack tare weight must not be negative -5"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.err, tt.pack.Validate())
+		})
+	}
+}
+
+func TestCosts(t *testing.T) {
+	// packs without a cost are left out, free packs are kept.
+	assert.Equal(t, map[int]int64{250: 10, 1000: 0}, Costs([]Pack{{Size: 250, Cost: cost(10)}, {Size: 500}, {Size: 1000, Cost: cost(0)}}))
+	assert.Empty(t, Costs(nil))
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
+}
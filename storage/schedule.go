@@ -0,0 +1,13 @@
+package storage
+
+import "time"
+
+// Schedule is a change of the pack set announced in advance, its packs replace every pack from EffectiveFrom.
+// Once due it is applied as a new version, sizes that are still stored keep their current stock.
+type Schedule struct {
+	ID            int       `json:"id"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedAt     time.Time `json:"created_at"`
+	Actor         string    `json:"actor,omitempty"`
+	Packs         []Pack    `json:"packs"`
+}
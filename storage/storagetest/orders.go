@@ -0,0 +1,225 @@
+package storagetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"reparttask/storage"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RunOrders runs the conformance suite of the order stores,
+// newStore must return a new, empty store for every test.
+func RunOrders(t *testing.T, newStore func(t *testing.T) storage.OrderStore) {
+	tests := map[string]func(t *testing.T, db storage.OrderStore){
+		"add order":           testAddOrder,
+		"add order copies":    testAddOrderCopies,
+		"get order not found": testGetOrderNotFound,
+		"list orders":         testListOrders,
+		"list orders filter":  testListOrdersFilter,
+		"list orders page":    testListOrdersPage,
+		"orders cancelled":    testOrdersCancelledContext,
+		"concurrent orders":   testConcurrentOrders,
+	}
+
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			tests[name](t, newStore(t))
+		})
+	}
+}
+
+func order(sku string, quantity int) storage.Order {
+	return storage.Order{
+		SKU:      sku,
+		Quantity: quantity,
+		Version:  1,
+		Result:   json.RawMessage(fmt.Sprintf(`{"packs":[{"size":%d,"quantity":1}]}`, quantity)),
+	}
+}
+
+func addOrder(t *testing.T, db storage.OrderStore, o storage.Order) storage.Order {
+	t.Helper()
+
+	stored, err := db.AddOrder(ctx, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return stored
+}
+
+func list(t *testing.T, db storage.OrderStore, filter storage.OrderFilter) ([]int, int) {
+	t.Helper()
+
+	orders, total, err := db.ListOrders(ctx, filter)
+	assert.NoError(t, err)
+
+	ids := []int{}
+	for _, o := range orders {
+		ids = append(ids, o.ID)
+	}
+
+	return ids, total
+}
+
+func testAddOrder(t *testing.T, db storage.OrderStore) {
+	c := newClock(t, db)
+
+	o := order(storage.DefaultSKU, 250)
+	o.Actor = "alice"
+	o.Params = map[string]string{"mode": "exact"}
+
+	stored := addOrder(t, db, o)
+	o.ID, o.CreatedAt = 1, c.Now()
+	assert.Equal(t, o, stored)
+
+	got, err := db.GetOrder(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, o, got)
+
+	// IDs follow the order the orders are stored in.
+	assert.Equal(t, 2, addOrder(t, db, order(storage.DefaultSKU, 500)).ID)
+}
+
+func testAddOrderCopies(t *testing.T, db storage.OrderStore) {
+	o := order(storage.DefaultSKU, 250)
+	o.Params = map[string]string{"mode": "exact"}
+	stored := addOrder(t, db, o)
+
+	// neither the input nor the output share the params or the result with the store.
+	o.Params["mode"] = "allow-short"
+	stored.Params["mode"] = "max-surplus"
+	o.Result[2] = 'x'
+	stored.Result[3] = 'x'
+
+	got, err := db.GetOrder(ctx, stored.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"mode": "exact"}, got.Params)
+	assert.JSONEq(t, `{"packs":[{"size":250,"quantity":1}]}`, string(got.Result))
+}
+
+func testGetOrderNotFound(t *testing.T, db storage.OrderStore) {
+	addOrder(t, db, order(storage.DefaultSKU, 250))
+
+	for _, id := range []int{0, 2, -1} {
+		_, err := db.GetOrder(ctx, id)
+		assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+	}
+}
+
+func testListOrders(t *testing.T, db storage.OrderStore) {
+	ids, total := list(t, db, storage.OrderFilter{})
+	assert.Empty(t, ids)
+	assert.Equal(t, 0, total)
+
+	for i := 1; i <= 3; i++ {
+		addOrder(t, db, order(storage.DefaultSKU, i*250))
+	}
+
+	// newest first.
+	ids, total = list(t, db, storage.OrderFilter{})
+	assert.Equal(t, []int{3, 2, 1}, ids)
+	assert.Equal(t, 3, total)
+}
+
+func testListOrdersFilter(t *testing.T, db storage.OrderStore) {
+	c := newClock(t, db)
+	start := c.Now()
+
+	addOrder(t, db, order(storage.DefaultSKU, 250))
+	addOrder(t, db, order("SKU-1", 23))
+	c.Add(time.Hour)
+	addOrder(t, db, order(storage.DefaultSKU, 500))
+	c.Add(time.Hour)
+	addOrder(t, db, order("SKU-1", 31))
+
+	ids, total := list(t, db, storage.OrderFilter{SKU: "SKU-1"})
+	assert.Equal(t, []int{4, 2}, ids)
+	assert.Equal(t, 2, total)
+
+	// from is inclusive, to exclusive.
+	ids, _ = list(t, db, storage.OrderFilter{From: start.Add(time.Hour)})
+	assert.Equal(t, []int{4, 3}, ids)
+
+	ids, _ = list(t, db, storage.OrderFilter{To: start.Add(time.Hour)})
+	assert.Equal(t, []int{2, 1}, ids)
+
+	ids, total = list(t, db, storage.OrderFilter{SKU: storage.DefaultSKU, From: start.Add(time.Hour), To: start.Add(2 * time.Hour)})
+	assert.Equal(t, []int{3}, ids)
+	assert.Equal(t, 1, total)
+}
+
+func testListOrdersPage(t *testing.T, db storage.OrderStore) {
+	for i := 1; i <= 5; i++ {
+		addOrder(t, db, order(storage.DefaultSKU, i*250))
+	}
+
+	ids, total := list(t, db, storage.OrderFilter{Limit: 2})
+	assert.Equal(t, []int{5, 4}, ids)
+	assert.Equal(t, 5, total)
+
+	ids, _ = list(t, db, storage.OrderFilter{Offset: 2, Limit: 2})
+	assert.Equal(t, []int{3, 2}, ids)
+
+	ids, _ = list(t, db, storage.OrderFilter{Offset: 4, Limit: 2})
+	assert.Equal(t, []int{1}, ids)
+
+	// a page past the last order is empty, the total still counts every match.
+	ids, total = list(t, db, storage.OrderFilter{Offset: 10, Limit: 2})
+	assert.Empty(t, ids)
+	assert.Equal(t, 5, total)
+}
+
+func testOrdersCancelledContext(t *testing.T, db storage.OrderStore) {
+	addOrder(t, db, order(storage.DefaultSKU, 250))
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	_, err := db.AddOrder(cancelled, order(storage.DefaultSKU, 500))
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.GetOrder(cancelled, 1)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, _, err = db.ListOrders(cancelled, storage.OrderFilter{})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	ids, _ := list(t, db, storage.OrderFilter{})
+	assert.Equal(t, []int{1}, ids)
+}
+
+func testConcurrentOrders(t *testing.T, db storage.OrderStore) {
+	const orders = 40
+
+	var wg sync.WaitGroup
+	for i := 0; i < orders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := db.AddOrder(ctx, order(storage.DefaultSKU, i+1)); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	// every order gets its own ID, without gaps.
+	ids, total := list(t, db, storage.OrderFilter{})
+	assert.Equal(t, orders, total)
+	for i, id := range ids {
+		assert.Equal(t, orders-i, id)
+	}
+}
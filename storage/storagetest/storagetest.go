@@ -0,0 +1,842 @@
+// Package storagetest checks that a storage.Storage implementation behaves like the others.
+// Every backend runs the same suite from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage { return NewMemDB() })
+//	}
+//
+// The order stores run RunOrders the same way.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"reparttask/storage"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Run runs the conformance suite, newStorage must return a new, empty storage for every test.
+func Run(t *testing.T, newStorage func(t *testing.T) storage.Storage) {
+	tests := map[string]func(t *testing.T, db storage.Storage){
+		"empty":                  testEmpty,
+		"add packs sorted":       testAddPacksSorted,
+		"add packs duplicates":   testAddPacksDuplicates,
+		"add packs keeps fields": testAddPacksKeepsFields,
+		"add packs invalid":      testAddPacksInvalid,
+		"add packs copies input": testAddPacksCopiesInput,
+		"remove pack":            testRemovePack,
+		"remove pack not found":  testRemovePackNotFound,
+		"remove packs":           testRemovePacks,
+		"replace packs":          testReplacePacks,
+		"replace packs version":  testReplacePacksVersion,
+		"replace packs invalid":  testReplacePacksInvalid,
+		"snapshot":               testSnapshot,
+		"reserve packs":          testReservePacks,
+		"reserve packs failed":   testReservePacksFailed,
+		"levels":                 testLevels,
+		"levels invalid":         testLevelsInvalid,
+		"cancelled context":      testCancelledContext,
+		"concurrent changes":     testConcurrentChanges,
+		"concurrent reservation": testConcurrentReservation,
+		"concurrent replace":     testConcurrentReplace,
+		"history":                testHistory,
+		"history unchanged":      testHistoryUnchanged,
+		"rollback":               testRollback,
+		"rollback stock":         testRollbackStock,
+		"rollback not found":     testRollbackNotFound,
+		"schedule packs":         testSchedulePacks,
+		"schedule packs invalid": testSchedulePacksInvalid,
+		"schedule packs stock":   testSchedulePacksStock,
+		"schedule order":         testScheduleOrder,
+		"schedule later change":  testScheduleLaterChange,
+		"schedule version time":  testScheduleVersionTime,
+		"cancel schedule":        testCancelSchedule,
+		"active packs history":   testActivePacksHistory,
+	}
+
+	names := make([]string, 0, len(tests))
+	for name := range tests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			tests[name](t, newStorage(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+func packs(t *testing.T, db storage.Storage) []storage.Pack {
+	t.Helper()
+
+	packs, err := db.GetPacks(ctx)
+	assert.NoError(t, err)
+	return packs
+}
+
+func levels(t *testing.T, db storage.Storage) []storage.Level {
+	t.Helper()
+
+	levels, err := db.GetLevels(ctx)
+	assert.NoError(t, err)
+	return levels
+}
+
+// clock is a fake clock, set on the storages with SetClock.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+// newClock sets a fake clock on db, the test is skipped when the store has no clock to set.
+func newClock(t *testing.T, db any) *clock {
+	t.Helper()
+
+	clocked, ok := db.(interface{ SetClock(now func() time.Time) })
+	if !ok {
+		t.Skip("the clock of the storage can't be set")
+	}
+
+	c := &clock{now: time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)}
+	clocked.SetClock(c.Now)
+	return c
+}
+
+func active(t *testing.T, db storage.Storage, at time.Time) []storage.Pack {
+	t.Helper()
+
+	set, err := db.ActivePackSet(ctx, at)
+	assert.NoError(t, err)
+	return set.Packs
+}
+
+func schedule(t *testing.T, db storage.Storage, from time.Time, packs ...storage.Pack) storage.Schedule {
+	t.Helper()
+
+	s, err := db.SchedulePacks(ctx, packs, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func apply(t *testing.T, db storage.Storage) int {
+	t.Helper()
+
+	applied, err := db.ApplySchedules(ctx)
+	assert.NoError(t, err)
+	return applied
+}
+
+func history(t *testing.T, db storage.Storage) []storage.PackSet {
+	t.Helper()
+
+	history, err := db.History(ctx)
+	assert.NoError(t, err)
+	return history
+}
+
+func add(t *testing.T, db storage.Storage, packs ...storage.Pack) {
+	t.Helper()
+
+	if err := db.AddPacks(ctx, packs); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testEmpty(t *testing.T, db storage.Storage) {
+	assert.Empty(t, packs(t, db))
+	assert.Empty(t, levels(t, db))
+}
+
+func testAddPacksSorted(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 1000}, storage.Pack{Size: 250})
+	add(t, db, storage.Pack{Size: 500})
+
+	assert.Equal(t, []int{250, 500, 1000}, storage.Sizes(packs(t, db)))
+}
+
+func testAddPacksDuplicates(t *testing.T, db storage.Storage) {
+	// the last pack of a size wins, within a call and across calls.
+	add(t, db, storage.Pack{Size: 250, Cost: cost(5)}, storage.Pack{Size: 250, Cost: cost(10)})
+	add(t, db, storage.Pack{Size: 500, Cost: cost(12)}, storage.Pack{Size: 250, Cost: cost(11), Name: "Small box"})
+
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(11), Name: "Small box"}, {Size: 500, Cost: cost(12)}}, packs(t, db))
+}
+
+func testAddPacksKeepsFields(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Cost: cost(10), Stock: &stock, Name: "Small box"})
+
+	// re-posting a size only changes the fields sent.
+	add(t, db, storage.Pack{Size: 250})
+	add(t, db, storage.Pack{Size: 250, Cost: cost(11)})
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(11), Stock: &stock, Name: "Small box"}}, packs(t, db))
+
+	// a zero cost is sent, the pack becomes free.
+	add(t, db, storage.Pack{Size: 250, Cost: cost(0)})
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(0), Stock: &stock, Name: "Small box"}}, packs(t, db))
+}
+
+func testAddPacksInvalid(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250})
+
+	// an invalid pack rejects the whole call.
+	err := db.AddPacks(ctx, []storage.Pack{{Size: 500}, {Size: 0}})
+	assert.True(t, errors.Is(err, storage.ErrInvalidSize), err)
+
+	err = db.AddPacks(ctx, []storage.Pack{{Size: 500}, {Size: 1000, Cost: cost(-1)}})
+	assert.True(t, errors.Is(err, storage.ErrInvalid), err)
+
+	assert.Equal(t, []int{250}, storage.Sizes(packs(t, db)))
+}
+
+func testAddPacksCopiesInput(t *testing.T, db storage.Storage) {
+	stock := 5
+	dimensions := storage.Dimensions{Length: 300, Width: 200, Height: 100}
+	input := []storage.Pack{{Size: 250, Stock: &stock, Dimensions: &dimensions}}
+	add(t, db, input...)
+
+	// the caller may reuse its packs afterwards.
+	stock = 1
+	dimensions.Length = 1
+	input[0].Size = 1
+
+	stored := packs(t, db)
+	assert.Equal(t, 250, stored[0].Size)
+	assert.Equal(t, 5, *stored[0].Stock)
+	assert.Equal(t, 300, stored[0].Dimensions.Length)
+}
+
+func testRemovePack(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250}, storage.Pack{Size: 500}, storage.Pack{Size: 1000})
+
+	assert.NoError(t, db.RemovePack(ctx, 500))
+	assert.Equal(t, []int{250, 1000}, storage.Sizes(packs(t, db)))
+}
+
+func testRemovePackNotFound(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250})
+
+	err := db.RemovePack(ctx, 500)
+	assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+
+	assert.NoError(t, db.RemovePack(ctx, 250))
+	err = db.RemovePack(ctx, 250)
+	assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+}
+
+func testRemovePacks(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250}, storage.Pack{Size: 500})
+	assert.NoError(t, db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{6}}}))
+
+	// only the packs are removed, the levels are kept.
+	assert.NoError(t, db.RemovePacks(ctx))
+	assert.Empty(t, packs(t, db))
+	assert.Len(t, levels(t, db), 1)
+
+	// removing nothing is not an error.
+	assert.NoError(t, db.RemovePacks(ctx))
+}
+
+func testReplacePacks(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500})
+
+	// nothing of the previous packs is kept, the last pack of a size wins.
+	err := db.ReplacePacks(ctx, []storage.Pack{{Size: 1000}, {Size: 250, Cost: cost(2)}, {Size: 250, Cost: cost(3)}}, storage.AnyVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(3)}, {Size: 1000}}, packs(t, db))
+
+	assert.NoError(t, db.ReplacePacks(ctx, nil, storage.AnyVersion))
+	assert.Empty(t, packs(t, db))
+}
+
+func testReplacePacksVersion(t *testing.T, db storage.Storage) {
+	// the version of an empty storage can be matched too.
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 250}}, 0))
+	assert.Len(t, history(t, db), 1)
+
+	add(t, db, storage.Pack{Size: 500})
+
+	// the packs changed since the version was read.
+	err := db.ReplacePacks(ctx, []storage.Pack{{Size: 1000}}, 1)
+	assert.True(t, errors.Is(err, storage.ErrConflict), err)
+	assert.EqualError(t, err, "packs were changed, the current version is 2")
+	assert.Equal(t, []int{250, 500}, storage.Sizes(packs(t, db)))
+
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 1000}}, 2))
+	assert.Equal(t, []int{1000}, storage.Sizes(packs(t, db)))
+
+	// a reservation doesn't record a version, so it doesn't conflict.
+	stock := 5
+	add(t, db, storage.Pack{Size: 1000, Stock: &stock})
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{1000: 1}))
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 250}}, 4))
+}
+
+func testReplacePacksInvalid(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250})
+
+	err := db.ReplacePacks(ctx, []storage.Pack{{Size: 500}, {Size: -1}}, storage.AnyVersion)
+	assert.True(t, errors.Is(err, storage.ErrInvalidSize), err)
+	assert.Equal(t, []int{250}, storage.Sizes(packs(t, db)))
+}
+
+func testSnapshot(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500})
+	snapshot := packs(t, db)
+
+	add(t, db, storage.Pack{Size: 1000})
+	assert.NoError(t, db.RemovePack(ctx, 500))
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 2}))
+
+	// packs read before a change are not affected by it.
+	assert.Equal(t, []int{250, 500}, storage.Sizes(snapshot))
+	assert.Equal(t, 5, *snapshot[0].Stock)
+}
+
+func testReservePacks(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500})
+
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 2, 500: 100}))
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 3}))
+
+	stored := packs(t, db)
+	assert.Equal(t, 0, *stored[0].Stock)
+	// unlimited sizes stay unlimited.
+	assert.Nil(t, stored[1].Stock)
+}
+
+func testReservePacksFailed(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500, Stock: &stock})
+
+	// a failed reservation takes nothing out of stock.
+	err := db.ReservePacks(ctx, map[int]int{250: 1, 500: 6})
+	assert.True(t, errors.Is(err, storage.ErrInsufficientStock), err)
+	assert.True(t, errors.Is(err, storage.ErrConflict), err)
+
+	err = db.ReservePacks(ctx, map[int]int{250: 1, 1000: 1})
+	assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+
+	stored := packs(t, db)
+	assert.Equal(t, 5, *stored[0].Stock)
+	assert.Equal(t, 5, *stored[1].Stock)
+}
+
+func testLevels(t *testing.T, db storage.Storage) {
+	input := []storage.Level{{Name: "carton", Capacities: []int{3, 6}}, {Name: "pallet", Capacities: []int{10}}}
+	assert.NoError(t, db.SetLevels(ctx, input))
+
+	// neither the input nor the output share the capacities with the storage.
+	input[0].Capacities[0] = 1
+	got := levels(t, db)
+	got[1].Capacities[0] = 1
+	assert.Equal(t, []storage.Level{{Name: "carton", Capacities: []int{3, 6}}, {Name: "pallet", Capacities: []int{10}}}, levels(t, db))
+
+	assert.NoError(t, db.SetLevels(ctx, nil))
+	assert.Empty(t, levels(t, db))
+}
+
+func testLevelsInvalid(t *testing.T, db storage.Storage) {
+	assert.NoError(t, db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{6}}}))
+
+	err := db.SetLevels(ctx, []storage.Level{{Name: "pallet", Capacities: []int{10}}, {Name: "pallet", Capacities: []int{20}}})
+	assert.True(t, errors.Is(err, storage.ErrInvalid), err)
+
+	assert.Equal(t, []storage.Level{{Name: "carton", Capacities: []int{6}}}, levels(t, db))
+}
+
+func testCancelledContext(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250})
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	// changes are not applied once the context is done.
+	assert.True(t, errors.Is(db.AddPacks(cancelled, []storage.Pack{{Size: 500}}), context.Canceled))
+	assert.True(t, errors.Is(db.RemovePack(cancelled, 250), context.Canceled))
+	assert.True(t, errors.Is(db.RemovePacks(cancelled), context.Canceled))
+	assert.True(t, errors.Is(db.ReplacePacks(cancelled, nil, storage.AnyVersion), context.Canceled))
+	assert.True(t, errors.Is(db.ReservePacks(cancelled, map[int]int{250: 1}), context.Canceled))
+	assert.True(t, errors.Is(db.SetLevels(cancelled, nil), context.Canceled))
+	assert.True(t, errors.Is(db.Rollback(cancelled, 1), context.Canceled))
+	_, err := db.SchedulePacks(cancelled, nil, time.Now().Add(time.Hour))
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, errors.Is(db.CancelSchedule(cancelled, 1), context.Canceled))
+	_, err = db.ApplySchedules(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	// nor are the reads answered.
+	_, err = db.GetPacks(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.GetLevels(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.History(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.PackSet(cancelled, 1)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.Schedules(cancelled)
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, err = db.ActivePackSet(cancelled, time.Time{})
+	assert.True(t, errors.Is(err, context.Canceled))
+
+	assert.Equal(t, []int{250}, storage.Sizes(packs(t, db)))
+}
+
+func testConcurrentChanges(t *testing.T, db storage.Storage) {
+	const (
+		workers    = 8
+		iterations = 50
+	)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+
+			for i := 0; i < iterations; i++ {
+				size := (w*iterations+i)%20 + 1
+				switch i % 5 {
+				case 0, 1:
+					stock := 100
+					_ = db.AddPacks(ctx, []storage.Pack{{Size: size, Stock: &stock}, {Size: size + 20}})
+				case 2:
+					_ = db.RemovePack(ctx, size)
+				case 3:
+					_ = db.ReservePacks(ctx, map[int]int{size: 1})
+				case 4:
+					_ = db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{size}}})
+				}
+
+				// every read must be sorted without duplicates, whatever runs next to it.
+				sizes := storage.Sizes(packs(t, db))
+				for j := 1; j < len(sizes); j++ {
+					if sizes[j] <= sizes[j-1] {
+						t.Errorf("sizes are not sorted and distinct %v", sizes)
+						return
+					}
+				}
+
+				levels(t, db)
+			}
+		}(w)
+	}
+
+	wg.Wait()
+}
+
+func testConcurrentReservation(t *testing.T, db storage.Storage) {
+	const (
+		stock        = 10
+		reservations = 40
+	)
+
+	left := stock
+	add(t, db, storage.Pack{Size: 250, Stock: &left})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, reservations)
+	for i := 0; i < reservations; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- db.ReservePacks(ctx, map[int]int{250: 1})
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// the same pack is never reserved twice.
+	reserved := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			reserved++
+		case !errors.Is(err, storage.ErrInsufficientStock):
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+
+	assert.Equal(t, stock, reserved)
+	assert.Equal(t, 0, *packs(t, db)[0].Stock)
+}
+
+func testConcurrentReplace(t *testing.T, db storage.Storage) {
+	const editors = 20
+
+	add(t, db, storage.Pack{Size: 250})
+	version := len(history(t, db))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, editors)
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- db.ReplacePacks(ctx, []storage.Pack{{Size: 1000 + i}}, version)
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	// only the first editor of that version wins, the others must read the packs again.
+	replaced := 0
+	for err := range errs {
+		switch {
+		case err == nil:
+			replaced++
+		case !errors.Is(err, storage.ErrConflict):
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+
+	assert.Equal(t, 1, replaced)
+	assert.Len(t, packs(t, db), 1)
+}
+
+func testHistory(t *testing.T, db storage.Storage) {
+	assert.Empty(t, history(t, db))
+
+	add(t, db, storage.Pack{Size: 250}, storage.Pack{Size: 500})
+	add(t, db, storage.Pack{Size: 250, Cost: cost(10)}, storage.Pack{Size: 1000})
+	assert.NoError(t, db.RemovePack(storage.WithActor(ctx, "alice"), 500))
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 750}}, storage.AnyVersion))
+	assert.NoError(t, db.RemovePacks(ctx))
+
+	versions := history(t, db)
+	assert.Len(t, versions, 5)
+
+	for i, version := range versions {
+		assert.Equal(t, i+1, version.Version)
+		assert.False(t, version.CreatedAt.IsZero())
+	}
+
+	assert.Equal(t, storage.ChangeAdd, versions[0].Change)
+	assert.Equal(t, storage.Diff{Added: []storage.Pack{{Size: 250}, {Size: 500}}}, versions[0].Diff)
+
+	assert.Equal(t, storage.Diff{Added: []storage.Pack{{Size: 1000}}, Changed: []storage.Pack{{Size: 250, Cost: cost(10)}}}, versions[1].Diff)
+	assert.Equal(t, []int{250, 500, 1000}, storage.Sizes(versions[1].Packs))
+
+	assert.Equal(t, storage.ChangeRemove, versions[2].Change)
+	assert.Equal(t, "alice", versions[2].Actor)
+	assert.Equal(t, storage.Diff{Removed: []storage.Pack{{Size: 500}}}, versions[2].Diff)
+
+	assert.Equal(t, storage.ChangeReplace, versions[3].Change)
+	assert.Equal(t, []int{750}, storage.Sizes(versions[3].Packs))
+
+	assert.Equal(t, storage.ChangeRemoveAll, versions[4].Change)
+	assert.Empty(t, versions[4].Packs)
+
+	// a version is the same whether it is listed or read alone.
+	version, err := db.PackSet(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, versions[1], version)
+
+	_, err = db.PackSet(ctx, 6)
+	assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+}
+
+func testHistoryUnchanged(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock})
+
+	// only changes of the pack set record a version.
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 1}))
+	assert.NoError(t, db.SetLevels(ctx, []storage.Level{{Name: "carton", Capacities: []int{6}}}))
+	assert.NoError(t, db.RemovePacks(ctx))
+	assert.NoError(t, db.RemovePacks(ctx))
+	assert.Error(t, db.RemovePack(ctx, 250))
+	assert.Error(t, db.AddPacks(ctx, []storage.Pack{{Size: -1}}))
+
+	assert.Len(t, history(t, db), 2)
+
+	// a version keeps the packs as they were when it was recorded.
+	assert.Equal(t, 5, *history(t, db)[0].Packs[0].Stock)
+}
+
+func testRollback(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250, Cost: cost(5)}, storage.Pack{Size: 500})
+	assert.NoError(t, db.ReplacePacks(ctx, []storage.Pack{{Size: 250, Cost: cost(10)}, {Size: 1000}}, storage.AnyVersion))
+
+	assert.NoError(t, db.Rollback(storage.WithActor(ctx, "bob"), 1))
+	assert.Equal(t, []storage.Pack{{Size: 250, Cost: cost(5)}, {Size: 500}}, packs(t, db))
+
+	// the rollback is a new version, the history is kept.
+	versions := history(t, db)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, storage.ChangeRollback, versions[2].Change)
+	assert.Equal(t, 1, versions[2].Source)
+	assert.Equal(t, "bob", versions[2].Actor)
+	assert.Equal(t, storage.Diff{
+		Added:   []storage.Pack{{Size: 500}},
+		Removed: []storage.Pack{{Size: 1000}},
+		Changed: []storage.Pack{{Size: 250, Cost: cost(5)}},
+	}, versions[2].Diff)
+
+	// a rollback can be rolled back too.
+	assert.NoError(t, db.Rollback(ctx, 2))
+	assert.Equal(t, []int{250, 1000}, storage.Sizes(packs(t, db)))
+	assert.Len(t, history(t, db), 4)
+}
+
+func testRollbackStock(t *testing.T, db storage.Storage) {
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500, Stock: &stock})
+	assert.NoError(t, db.RemovePack(ctx, 500))
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 2}))
+
+	// reserved packs are not put back in stock, removed sizes get their stock back.
+	assert.NoError(t, db.Rollback(ctx, 1))
+	stored := packs(t, db)
+	assert.Equal(t, 3, *stored[0].Stock)
+	assert.Equal(t, 5, *stored[1].Stock)
+}
+
+func testRollbackNotFound(t *testing.T, db storage.Storage) {
+	add(t, db, storage.Pack{Size: 250})
+
+	for _, version := range []int{0, 2, -1} {
+		err := db.Rollback(ctx, version)
+		assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+	}
+
+	assert.Len(t, history(t, db), 1)
+}
+
+func testSchedulePacks(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	add(t, db, storage.Pack{Size: 250}, storage.Pack{Size: 500})
+
+	from := c.Now().Add(time.Hour)
+	s, err := db.SchedulePacks(storage.WithActor(ctx, "alice"), []storage.Pack{{Size: 1000}, {Size: 300}}, from)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.Schedule{
+		ID:            1,
+		EffectiveFrom: from,
+		CreatedAt:     c.Now(),
+		Actor:         "alice",
+		Packs:         []storage.Pack{{Size: 300}, {Size: 1000}},
+	}, s)
+
+	schedules, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Schedule{s}, schedules)
+
+	// nothing changes before the effective time.
+	assert.Equal(t, 0, apply(t, db))
+	assert.Equal(t, []int{250, 500}, storage.Sizes(packs(t, db)))
+	assert.Equal(t, []int{250, 500}, storage.Sizes(active(t, db, time.Time{})))
+	assert.Equal(t, []int{300, 1000}, storage.Sizes(active(t, db, from)))
+
+	// once due the schedule is active, even before it is applied.
+	c.Add(time.Hour)
+	assert.Equal(t, []int{300, 1000}, storage.Sizes(active(t, db, time.Time{})))
+	assert.Equal(t, []int{250, 500}, storage.Sizes(packs(t, db)))
+
+	assert.Equal(t, 1, apply(t, db))
+	assert.Equal(t, []int{300, 1000}, storage.Sizes(packs(t, db)))
+	assert.Equal(t, 0, apply(t, db))
+
+	schedules, err = db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	versions := history(t, db)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, storage.ChangeSchedule, versions[1].Change)
+	assert.Equal(t, 1, versions[1].Schedule)
+	assert.Equal(t, "alice", versions[1].Actor)
+	assert.Equal(t, from, versions[1].CreatedAt)
+}
+
+func testSchedulePacksInvalid(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+
+	_, err := db.SchedulePacks(ctx, []storage.Pack{{Size: 250}}, c.Now())
+	assert.True(t, errors.Is(err, storage.ErrInvalid), err)
+
+	_, err = db.SchedulePacks(ctx, []storage.Pack{{Size: 250}, {Size: 0}}, c.Now().Add(time.Hour))
+	assert.True(t, errors.Is(err, storage.ErrInvalidSize), err)
+
+	schedules, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, schedules)
+}
+
+func testSchedulePacksStock(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock})
+	schedule(t, db, c.Now().Add(time.Hour), storage.Pack{Size: 250, Stock: &stock}, storage.Pack{Size: 500, Stock: &stock})
+
+	// packs reserved before the schedule is applied stay reserved.
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 2}))
+
+	c.Add(time.Hour)
+	assert.Equal(t, 1, apply(t, db))
+
+	stored := packs(t, db)
+	assert.Equal(t, 3, *stored[0].Stock)
+	assert.Equal(t, 5, *stored[1].Stock)
+}
+
+func testScheduleOrder(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	start := c.Now()
+
+	// schedules are applied by effective time, whatever the order they were made in.
+	late := schedule(t, db, start.Add(2*time.Hour), storage.Pack{Size: 2000})
+	early := schedule(t, db, start.Add(time.Hour), storage.Pack{Size: 1000})
+
+	schedules, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Schedule{early, late}, schedules)
+
+	assert.Equal(t, []int{1000}, storage.Sizes(active(t, db, start.Add(90*time.Minute))))
+	assert.Equal(t, []int{2000}, storage.Sizes(active(t, db, start.Add(3*time.Hour))))
+
+	c.Add(3 * time.Hour)
+	assert.Equal(t, 2, apply(t, db))
+	assert.Equal(t, []int{2000}, storage.Sizes(packs(t, db)))
+
+	versions := history(t, db)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, early.ID, versions[0].Schedule)
+	assert.Equal(t, late.ID, versions[1].Schedule)
+}
+
+func testScheduleLaterChange(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	add(t, db, storage.Pack{Size: 250})
+	from := c.Now().Add(time.Hour)
+	schedule(t, db, from, storage.Pack{Size: 300})
+
+	// a change made once the schedule is due applies it first, the scheduler finds nothing left to overwrite it with.
+	c.Add(2 * time.Hour)
+	add(t, db, storage.Pack{Size: 2000})
+	assert.Equal(t, 0, apply(t, db))
+	assert.Equal(t, []int{300, 2000}, storage.Sizes(packs(t, db)))
+
+	versions := history(t, db)
+	assert.Len(t, versions, 3)
+	assert.Equal(t, storage.ChangeSchedule, versions[1].Change)
+	assert.Equal(t, storage.ChangeAdd, versions[2].Change)
+
+	// the same goes for a change that records no version.
+	schedule(t, db, c.Now().Add(time.Hour), storage.Pack{Size: 500})
+	c.Add(2 * time.Hour)
+	assert.NoError(t, db.SetLevels(ctx, nil))
+	assert.Equal(t, 0, apply(t, db))
+	assert.Equal(t, []int{500}, storage.Sizes(packs(t, db)))
+}
+
+func testScheduleVersionTime(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	start := c.Now()
+	add(t, db, storage.Pack{Size: 250})
+	schedule(t, db, start.Add(time.Hour), storage.Pack{Size: 300})
+
+	// the scheduler runs late, the version is still stamped when orders started to use it.
+	c.Add(3 * time.Hour)
+	assert.Equal(t, 1, apply(t, db))
+
+	versions := history(t, db)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, start.Add(time.Hour), versions[1].CreatedAt)
+
+	assert.Equal(t, []int{250}, storage.Sizes(active(t, db, start.Add(30*time.Minute))))
+	assert.Equal(t, []int{300}, storage.Sizes(active(t, db, start.Add(90*time.Minute))))
+}
+
+func testCancelSchedule(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	add(t, db, storage.Pack{Size: 250})
+
+	first := schedule(t, db, c.Now().Add(time.Hour), storage.Pack{Size: 1000})
+	second := schedule(t, db, c.Now().Add(2*time.Hour), storage.Pack{Size: 2000})
+
+	assert.NoError(t, db.CancelSchedule(ctx, first.ID))
+	err := db.CancelSchedule(ctx, first.ID)
+	assert.True(t, errors.Is(err, storage.ErrNotFound), err)
+
+	schedules, err := db.Schedules(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Schedule{second}, schedules)
+
+	// a cancelled schedule is never applied, IDs are not reused.
+	c.Add(90 * time.Minute)
+	assert.Equal(t, 0, apply(t, db))
+	assert.Equal(t, []int{250}, storage.Sizes(packs(t, db)))
+	assert.Equal(t, 3, schedule(t, db, c.Now().Add(time.Hour), storage.Pack{Size: 500}).ID)
+}
+
+func testActivePacksHistory(t *testing.T, db storage.Storage) {
+	c := newClock(t, db)
+	start := c.Now()
+
+	stock := 5
+	add(t, db, storage.Pack{Size: 250, Stock: &stock})
+	c.Add(time.Hour)
+	add(t, db, storage.Pack{Size: 500})
+	c.Add(time.Hour)
+	assert.NoError(t, db.ReservePacks(ctx, map[int]int{250: 1}))
+
+	// before the latest version, the version then active is returned as it was recorded.
+	assert.Empty(t, active(t, db, start.Add(-time.Minute)))
+	assert.Equal(t, []storage.Pack{{Size: 250, Stock: &stock}}, active(t, db, start.Add(30*time.Minute)))
+
+	// from the latest version on, the stored packs with their current stock.
+	four := 4
+	assert.Equal(t, []storage.Pack{{Size: 250, Stock: &four}, {Size: 500}}, active(t, db, start.Add(time.Hour)))
+	assert.Equal(t, packs(t, db), active(t, db, time.Time{}))
+
+	set, err := db.ActivePackSet(ctx, start.Add(30*time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, set.Version)
+
+	set, err = db.ActivePackSet(ctx, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, set.Version)
+
+	// a due schedule not applied yet has no version.
+	schedule(t, db, c.Now().Add(time.Hour), storage.Pack{Size: 1000})
+	set, err = db.ActivePackSet(ctx, c.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, set.Version)
+	assert.Equal(t, 1, set.Schedule)
+}
+
+// cost returns a pointer to the given pack cost.
+func cost(c int64) *int64 {
+	return &c
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"net/http"
+	"reparttask/storage"
+)
+
+// sku returns the product in the path, the default one for the unscoped endpoints.
+func sku(r *http.Request) string {
+	if sku := r.PathValue("sku"); sku != "" {
+		return sku
+	}
+
+	return storage.DefaultSKU
+}
+
+// LookupProduct returns the storage of the requested product,
+// on failure the error response is already written.
+func LookupProduct(w http.ResponseWriter, r *http.Request, products storage.Catalog) (storage.Storage, bool) {
+	db, ok := products.Lookup(sku(r))
+	if !ok {
+		WriteStorageError(w, storage.Errorf(storage.ErrNotFound, "product %q not found", sku(r)))
+		return nil, false
+	}
+
+	return db, true
+}
+
+// Product returns the storage of the requested product, a new product is added when needed,
+// on failure the error response is already written.
+func Product(w http.ResponseWriter, r *http.Request, products storage.Catalog) (storage.Storage, bool) {
+	db, err := products.Product(sku(r))
+	if err != nil {
+		WriteStorageError(w, err)
+		return nil, false
+	}
+
+	return db, true
+}
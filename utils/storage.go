@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reparttask/storage"
+)
+
+// ActorHeader names the author of the changes made by a request, it is recorded in the history of the packs.
+const ActorHeader = "X-Actor"
+
+// Context returns the context of the request, carrying the author of its changes.
+func Context(r *http.Request) context.Context {
+	return storage.WithActor(r.Context(), r.Header.Get(ActorHeader))
+}
+
+// StorageError returns the status code and message reported for a storage error,
+// unexpected errors are not exposed to the client. It also reports a done request context,
+// whatever was running when it ended, so a timeout gets the same response everywhere.
+func StorageError(err error) (int, string) {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, storage.ErrInvalidSize), errors.Is(err, storage.ErrInvalid):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, "request timed out"
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, "request cancelled"
+	default:
+		return http.StatusInternalServerError, "an error has occurred"
+	}
+}
+
+// WriteStorageError translates a storage error into the error response.
+func WriteStorageError(w http.ResponseWriter, err error) {
+	status, msg := StorageError(err)
+	WriteOutput(w, status, map[string]string{"error": msg})
+}